@@ -70,9 +70,9 @@ func (a *APCUPSDStatus) Status() (StatusBlock, error) {
 
 	if lines["STATUS"] != "ONLINE" {
 		if lines["STATUS"] == "ONBATT" {
-			status.Background = color.RGBA{R: 0xFF}
+			status.Background = color.RGBA{R: 0xFF, A: 0xFF}
 		} else {
-			status.Background = color.RGBA{R: 0xFF, G: 0xFF, B: 0x00}
+			status.Background = color.RGBA{R: 0xFF, G: 0xFF, B: 0x00, A: 0xFF}
 		}
 
 		timeleft, _ := parse(lines["TIMELEFT"])
@@ -86,6 +86,25 @@ func (a *APCUPSDStatus) Status() (StatusBlock, error) {
 	return a.lastStatus, nil
 }
 
+// Pause closes the connection to apcupsd
+func (a *APCUPSDStatus) Pause() {
+	if a.conn != nil {
+		a.conn.Close()
+		a.conn = nil
+	}
+}
+
+// Resume discards the cached status
+func (a *APCUPSDStatus) Resume() {
+	a.lastStatusExpiry = time.Time{}
+}
+
+// Close closes the connection to apcupsd
+func (a *APCUPSDStatus) Close() error {
+	a.Pause()
+	return nil
+}
+
 var suffixes = []string{
 	" Minutes",
 	" Seconds",
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"flag"
 	"time"
 
 	. "github.com/abextm/my3status"
 )
 
 func main() {
+	var flags Flags
+	flags.Register(flag.CommandLine)
+	flag.Parse()
+
 	RedirectStderr("/home/abex/.my3status")
 
 	Config{
@@ -31,28 +36,35 @@ func main() {
 			},
 			&Memory{},
 			&Edit{
-				Widget: Switcher{
-					&Time{
-						Format: `Monday January 01/02/2006 15:04:05`,
-					},
-					&Time{
-						Format:       `Mon 15:04 MST`,
-						LocationName: "UTC",
-					},
-					&Time{
-						Format:       `Mon 15:04 MST`,
-						LocationName: "Asia/Tokyo",
+				Widget: &StatefulSwitcher{
+					Widgets: Switcher{
+						&Time{
+							Format: `Monday January 01/02/2006 15:04:05`,
+						},
+						&Time{
+							Format:       `Mon 15:04 MST`,
+							LocationName: "UTC",
+						},
+						&Time{
+							Format:       `Mon 15:04 MST`,
+							LocationName: "Asia/Tokyo",
+						},
 					},
 				},
 				Func: func(s *StatusBlock) {
 					s.Separator.Width = IntPtr(8)
+					s.Name = "clock"
 				},
 			},
-			StatusBlock{}, // to make the previous separator apply
+			// i3bar only leaves a block's separator_block_width after it if
+			// another block follows, even one it hides for being empty. This
+			// keeps the clock's gap at the edge of the bar, so it must stay
+			// the last Widget
+			StatusBlock{},
 		},
 		DefaultSeparator: Separator{
 			Hide:  BoolPtr(true),
 			Width: IntPtr(24),
 		},
-	}.Loop()
+	}.Main(flags)
 }
@@ -0,0 +1,124 @@
+// exampleplugin is the reference my3status plugin. It counts clicks, left
+// clicking to count up and right clicking to reset. Use it from a config file
+// with
+//
+//	{"type": "plugin", "command": ["exampleplugin"]}
+//
+// A plugin can be written in any language. Every message is a line of JSON, so
+// a session looks like this, with > sent by the bar and < by the plugin:
+//
+//	> {"jsonrpc":"2.0","id":1,"method":"initialize","params":{"version":1,"timeout":30}}
+//	< {"jsonrpc":"2.0","id":1,"result":{"version":1}}
+//	< {"jsonrpc":"2.0","method":"update","params":{"blocks":[{"name":"clicks","full_text":"0 clicks"}]}}
+//	> {"jsonrpc":"2.0","method":"click","params":{"name":"clicks","instance":"1.1","button":1,...}}
+//	< {"jsonrpc":"2.0","method":"update","params":{"blocks":[{"name":"clicks","full_text":"1 clicks"}]}}
+//
+// The plugin must resend it's blocks at least every timeout seconds, and exit
+// when it's stdin is closed.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	. "github.com/abextm/my3status"
+)
+
+func main() {
+	messages := make(chan PluginMessage)
+	go func() {
+		defer close(messages)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			msg := PluginMessage{}
+			err := json.Unmarshal(scanner.Bytes(), &msg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "exampleplugin: %v\n", err)
+				continue
+			}
+			messages <- msg
+		}
+	}()
+
+	out := json.NewEncoder(os.Stdout)
+	send := func(msg PluginMessage) {
+		msg.JSONRPC = "2.0"
+		err := out.Encode(msg)
+		if err != nil {
+			// the bar has gone away
+			os.Exit(1)
+		}
+	}
+
+	clicks := 0
+	update := func() {
+		params, _ := json.Marshal(PluginUpdateParams{
+			Blocks: []StatusBlock{{
+				Name:     "clicks",
+				FullText: fmt.Sprintf("%d clicks", clicks),
+			}},
+		})
+		send(PluginMessage{
+			Method: PluginMethodUpdate,
+			Params: params,
+		})
+	}
+
+	// heartbeat is started once the timeout is known
+	var heartbeat <-chan time.Time
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			switch msg.Method {
+			case PluginMethodInitialize:
+				params := PluginInitializeParams{}
+				json.Unmarshal(msg.Params, &params)
+				if params.Version != PluginVersion {
+					send(PluginMessage{
+						ID:    msg.ID,
+						Error: &PluginError{Code: -32602, Message: "unsupported version"},
+					})
+					return
+				}
+				result, _ := json.Marshal(PluginInitializeResult{Version: PluginVersion})
+				send(PluginMessage{
+					ID:     msg.ID,
+					Result: result,
+				})
+				update()
+				if params.Timeout > 0 {
+					ticker := time.NewTicker(time.Duration(params.Timeout * float64(time.Second) / 2))
+					defer ticker.Stop()
+					heartbeat = ticker.C
+				}
+			case PluginMethodClick:
+				ev := ClickEvent{}
+				json.Unmarshal(msg.Params, &ev)
+				switch ev.Button {
+				case ButtonLeft:
+					clicks++
+				case ButtonRight:
+					clicks = 0
+				}
+				update()
+			default:
+				// notifications the plugin does not know are ignored, but
+				// requests must be answered
+				if msg.ID != nil && msg.Method != "" {
+					send(PluginMessage{
+						ID:    msg.ID,
+						Error: &PluginError{Code: -32601, Message: "method not found"},
+					})
+				}
+			}
+		case <-heartbeat:
+			update()
+		}
+	}
+}
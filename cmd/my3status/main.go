@@ -0,0 +1,72 @@
+// my3status is a status bar configured by a JSON file. See
+// my3status.LoadConfig for the format, and example.json for an example. An
+// existing i3blocks config can be used with -i3blocks, or converted to a JSON
+// config with -i3blocks file -convert.
+//
+// The empty text widget at the end of example.json is deliberate. i3bar only
+// leaves a block's separator_block_width after it if another block follows,
+// even one it hides for being empty, so it keeps the gap after the clock
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/abextm/my3status"
+)
+
+func main() {
+	configPath := flag.String("config", defaultConfigPath(), "the JSON config file to load")
+	stderr := flag.String("stderr", "", "append stderr to this file")
+	i3blocks := flag.String("i3blocks", "", "load this i3blocks config instead of the JSON config file")
+	convert := flag.Bool("convert", false, "print the -i3blocks config as a JSON config file and exit")
+	types := flag.Bool("types", false, "list the widget types that can be used in the config file")
+	var flags Flags
+	flags.Register(flag.CommandLine)
+	flag.Parse()
+
+	if *types {
+		fmt.Println(strings.Join(WidgetTypes(), "\n"))
+		return
+	}
+
+	if *convert {
+		data, err := ConvertI3blocksConfig(*i3blocks)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "my3status: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+		return
+	}
+
+	if *stderr != "" {
+		RedirectStderr(*stderr)
+	}
+
+	config := Config{}
+	if flags.WaybarClick == "" {
+		var err error
+		if *i3blocks != "" {
+			config, err = LoadI3blocksConfig(*i3blocks)
+		} else {
+			config, err = LoadConfig(*configPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "my3status: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	config.Main(flags)
+}
+
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "my3status", "config.json")
+}
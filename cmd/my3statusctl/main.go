@@ -0,0 +1,100 @@
+// my3statusctl sends commands to a running my3status listening on a control
+// socket. For example, to cycle a Switcher named clock from i3:
+//
+//	bindsym $mod+c exec my3statusctl click clock
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	. "github.com/abextm/my3status"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] command
+
+Commands:
+  list                   print the name, instance and text of every block
+  refresh                redraw the bar
+  click name [instance]  click a block
+  restart                restart the bar
+  post text...           post a message to a message widget
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	socket := flag.String("socket", DefaultControlSocket(), "the control socket of the bar")
+	button := flag.Int("button", ButtonLeft, "the button to click with")
+	to := flag.String("to", "message", "the message widget to post to")
+	color := flag.String("color", "", "the color of the posted message, as #RRGGBB")
+	urgent := flag.Bool("urgent", false, "mark the posted message as urgent")
+	ttl := flag.String("ttl", "", "how long to show the posted message for, such as 30s")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	req := ControlRequest{
+		Command: args[0],
+	}
+	switch req.Command {
+	case ControlList, ControlRefresh, ControlRestart:
+		if len(args) != 1 {
+			usage()
+			os.Exit(2)
+		}
+	case ControlClick:
+		if len(args) < 2 || len(args) > 3 {
+			usage()
+			os.Exit(2)
+		}
+		req.Name = args[1]
+		if len(args) == 3 {
+			req.Instance = args[2]
+		}
+		req.Button = *button
+	case ControlPost:
+		if len(args) < 2 {
+			usage()
+			os.Exit(2)
+		}
+		req.Name = *to
+		req.Message = &PostedMessage{
+			Text:   strings.Join(args[1:], " "),
+			Color:  *color,
+			Urgent: *urgent,
+			TTL:    *ttl,
+		}
+	}
+
+	resp, err := Control(*socket, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "my3statusctl: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, raw := range resp.Blocks {
+		block := struct {
+			Name     string `json:"name"`
+			Instance string `json:"instance"`
+			FullText string `json:"full_text"`
+		}{}
+		err := json.Unmarshal(raw, &block)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "my3statusctl: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s\t%s\t%s\n", block.Name, block.Instance, strings.Replace(block.FullText, "\n", " ", -1))
+	}
+}
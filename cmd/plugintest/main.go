@@ -0,0 +1,245 @@
+// plugintest checks that a my3status plugin follows the plugin protocol. It
+// runs the plugin, initializes it, clicks it's first block and closes it,
+// printing the result of every check. It exits with status 1 if any failed.
+//
+//	plugintest -timeout 2s python3 plugin.py
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	. "github.com/abextm/my3status"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] command [args...]\n\nFlags:\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+type checker struct {
+	timeout  time.Duration
+	messages chan PluginMessage
+	errors   chan error
+	failed   bool
+}
+
+func (c *checker) check(name string, err error) bool {
+	if err != nil {
+		c.failed = true
+		fmt.Printf("FAIL %v: %v\n", name, err)
+		return false
+	}
+	fmt.Printf("ok   %v\n", name)
+	return true
+}
+
+// next returns the next message from the plugin
+func (c *checker) next() (PluginMessage, error) {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			return msg, fmt.Errorf("plugin closed it's stdout")
+		}
+		return msg, nil
+	case err := <-c.errors:
+		return PluginMessage{}, err
+	case <-time.After(c.timeout):
+		return PluginMessage{}, fmt.Errorf("no message within %v", c.timeout)
+	}
+}
+
+// nextUpdate returns the blocks of the next update from the plugin
+func (c *checker) nextUpdate() ([]StatusBlock, error) {
+	msg, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+	if msg.Method != PluginMethodUpdate {
+		return nil, fmt.Errorf("expected %q, got %q", PluginMethodUpdate, msg.Method)
+	}
+	params := PluginUpdateParams{}
+	err = json.Unmarshal(msg.Params, &params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid params: %v", err)
+	}
+	if params.Error == "" && len(params.Blocks) == 0 {
+		return nil, fmt.Errorf("update has no blocks or error")
+	}
+	return params.Blocks, nil
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 5*time.Second, "how long to wait for each message, which is also sent to the plugin as it's timeout")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd := exec.Command(flag.Arg(0), flag.Args()[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "plugintest: %v\n", err)
+		os.Exit(1)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "plugintest: %v\n", err)
+		os.Exit(1)
+	}
+	err = cmd.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "plugintest: %v\n", err)
+		os.Exit(1)
+	}
+
+	c := &checker{
+		timeout:  *timeout,
+		messages: make(chan PluginMessage),
+		errors:   make(chan error, 1),
+	}
+	go func() {
+		defer close(c.messages)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			msg := PluginMessage{}
+			err := json.Unmarshal(scanner.Bytes(), &msg)
+			if err == nil && msg.JSONRPC != "2.0" {
+				err = fmt.Errorf("jsonrpc is %q, not \"2.0\"", msg.JSONRPC)
+			}
+			if err != nil {
+				c.errors <- fmt.Errorf("invalid message %q: %v", scanner.Text(), err)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+
+	send := func(method string, id *json.RawMessage, params interface{}) error {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		data, err = json.Marshal(PluginMessage{
+			JSONRPC: "2.0",
+			ID:      id,
+			Method:  method,
+			Params:  data,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = stdin.Write(append(data, '\n'))
+		return err
+	}
+
+	c.run(send)
+
+	stdin.Close()
+	exited := make(chan error, 1)
+	go func() {
+		// drain anything sent after the last check, so the plugin can exit
+		for range c.messages {
+		}
+		exited <- cmd.Wait()
+	}()
+	select {
+	case <-exited:
+		c.check("exits when stdin is closed", nil)
+	case <-time.After(*timeout):
+		cmd.Process.Kill()
+		c.check("exits when stdin is closed", fmt.Errorf("still running after %v", *timeout))
+	}
+
+	if c.failed {
+		os.Exit(1)
+	}
+}
+
+func (c *checker) run(send func(method string, id *json.RawMessage, params interface{}) error) {
+	id := json.RawMessage("1")
+	err := send(PluginMethodInitialize, &id, PluginInitializeParams{
+		Version: PluginVersion,
+		Timeout: c.timeout.Seconds(),
+	})
+	if !c.check("accepts initialize", err) {
+		return
+	}
+
+	// the response and the first update may arrive in either order
+	var blocks []StatusBlock
+	responded, updated := false, false
+	for !responded || !updated {
+		msg, err := c.next()
+		if err != nil {
+			if !responded {
+				c.check("responds to initialize", err)
+			}
+			if !updated {
+				c.check("sends an update", err)
+			}
+			return
+		}
+		switch {
+		case msg.Method == PluginMethodUpdate && !updated:
+			updated = true
+			params := PluginUpdateParams{}
+			err = json.Unmarshal(msg.Params, &params)
+			if err == nil && params.Error == "" && len(params.Blocks) == 0 {
+				err = fmt.Errorf("update has no blocks or error")
+			}
+			blocks = params.Blocks
+			if !c.check("sends an update", err) {
+				return
+			}
+		case msg.Method == "" && !responded:
+			responded = true
+			result := PluginInitializeResult{}
+			switch {
+			case msg.Error != nil:
+				err = msg.Error
+			case msg.ID == nil || string(*msg.ID) != "1":
+				err = fmt.Errorf("response has the wrong id")
+			default:
+				err = json.Unmarshal(msg.Result, &result)
+				if err == nil && result.Version != PluginVersion {
+					err = fmt.Errorf("version is %v, not %v", result.Version, PluginVersion)
+				}
+			}
+			if !c.check("responds to initialize", err) {
+				return
+			}
+		default:
+			c.check("responds to initialize", fmt.Errorf("unexpected message %q", msg.Method))
+			return
+		}
+	}
+
+	for i, b := range blocks {
+		if b.FullText == "" {
+			c.check(fmt.Sprintf("block %d has full_text", i), fmt.Errorf("full_text is empty, so the block is hidden"))
+		}
+	}
+
+	click := ClickEvent{
+		Button: ButtonLeft,
+	}
+	if len(blocks) > 0 {
+		click.Name = blocks[0].Name
+		click.Instance = blocks[0].Instance
+	}
+	err = send(PluginMethodClick, nil, click)
+	if !c.check("accepts a click", err) {
+		return
+	}
+	_, err = c.nextUpdate()
+	c.check("keeps sending updates within the timeout", err)
+}
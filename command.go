@@ -0,0 +1,80 @@
+package my3status
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Flags select which mode Main runs in. They are normally set from the command
+// line with Register
+type Flags struct {
+	// Waybar runs a single Widget as a Waybar custom module
+	Waybar string
+
+	// WaybarClick sends a click with Button to a running Waybar module
+	WaybarClick string
+	Button      int
+
+	// Tmux renders tmux status line markup, using ShortText if the bar is
+	// wider than TmuxWidth columns
+	Tmux      bool
+	TmuxWidth int
+
+	// Once prints a single frame and exits
+	Once bool
+
+	// Preview renders the bar in the terminal, with keyboard controlled clicks
+	Preview bool
+
+	// Supervise restarts the bar when it fails; see Config.Supervise
+	Supervise bool
+
+	// ControlSocket overrides Config.ControlSocket
+	ControlSocket string
+}
+
+// Register adds the flags to fs
+func (f *Flags) Register(fs *flag.FlagSet) {
+	fs.StringVar(&f.Waybar, "waybar", "", "run a single widget, by name or position, as a Waybar custom module")
+	fs.StringVar(&f.WaybarClick, "waybar-click", "", "send a click to a widget running with -waybar")
+	fs.IntVar(&f.Button, "button", ButtonLeft, "the button to send with -waybar-click")
+	fs.BoolVar(&f.Tmux, "tmux", false, "render tmux status line markup")
+	fs.IntVar(&f.TmuxWidth, "tmux-width", 0, "the number of columns available to -tmux")
+	fs.BoolVar(&f.Once, "once", false, "print a single frame and exit")
+	fs.BoolVar(&f.Preview, "preview", false, "preview the bar in the terminal")
+	fs.BoolVar(&f.Supervise, "supervise", false, "restart the bar when it fails, instead of exiting")
+	fs.StringVar(&f.ControlSocket, "control-socket", "", "listen for my3statusctl commands on this unix socket, or \"default\"")
+}
+
+// Main runs the mode selected by f, which is Loop unless any flags are set. It
+// exits the process if an error occurs
+func (c Config) Main(f Flags) {
+	c.Once = c.Once || f.Once
+	c.Supervise = c.Supervise || f.Supervise
+	if f.ControlSocket != "" {
+		c.ControlSocket = f.ControlSocket
+	}
+	if f.Tmux {
+		c.Renderer = Tmux{Width: f.TmuxWidth}
+	}
+
+	var err error
+	switch {
+	case f.WaybarClick != "":
+		err = WaybarClick(f.WaybarClick, f.Button)
+	case f.Preview:
+		err = c.Preview(context.Background())
+	case f.Waybar != "":
+		err = c.Waybar(context.Background(), f.Waybar)
+	case c.Renderer != nil || c.Once:
+		err = c.Run(context.Background(), os.Stdin, os.Stdout)
+	default:
+		c.Loop()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "my3status: %v\n", err)
+		os.Exit(1)
+	}
+}
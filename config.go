@@ -0,0 +1,578 @@
+package my3status
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A WidgetConstructor builds a Widget from it's configuration
+type WidgetConstructor func(c *WidgetConfig) (Widget, error)
+
+var registryLock = &sync.Mutex{}
+var registry = map[string]WidgetConstructor{}
+
+// RegisterWidget makes a widget type available to config files. Registering
+// the same type twice replaces the previous constructor
+func RegisterWidget(typ string, constructor WidgetConstructor) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[typ] = constructor
+}
+
+// WidgetTypes returns the names of all registered widget types
+func WidgetTypes() []string {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// WidgetConfig is the JSON object configuring a single Widget. Every object
+// has a "type" key selecting the WidgetConstructor, and may have any of the
+// keys of an "edit" widget to override fields of the Widget's blocks.
+type WidgetConfig struct {
+	Type string
+
+	// raw is the object's text, exactly as it appears in the config file, so
+	// errors can be positioned by searching for it
+	raw []byte
+}
+
+func (w *WidgetConfig) UnmarshalJSON(b []byte) error {
+	w.raw = append([]byte(nil), b...)
+	typ := struct {
+		Type string `json:"type"`
+	}{}
+	err := json.Unmarshal(b, &typ)
+	if err != nil {
+		return w.wrap(err)
+	}
+	if typ.Type == "" {
+		return w.Errorf("widget is missing a \"type\"")
+	}
+	w.Type = typ.Type
+	return nil
+}
+
+// Decode unmarshals the widget's JSON object into v
+func (w *WidgetConfig) Decode(v interface{}) error {
+	err := json.Unmarshal(w.raw, v)
+	if err != nil {
+		return w.wrap(err)
+	}
+	return nil
+}
+
+// key returns the object's text without insignificant whitespace
+func (w *WidgetConfig) key() string {
+	buf := &bytes.Buffer{}
+	err := json.Compact(buf, w.raw)
+	if err != nil {
+		return string(w.raw)
+	}
+	return buf.String()
+}
+
+// Errorf returns an error positioned at the start of the widget's object
+func (w *WidgetConfig) Errorf(format string, args ...interface{}) error {
+	return &positionedError{
+		in:  w.raw,
+		err: fmt.Errorf(format, args...),
+	}
+}
+
+// wrap positions an error returned by encoding/json
+func (w *WidgetConfig) wrap(err error) error {
+	if _, ok := err.(*positionedError); ok {
+		return err
+	}
+	offset := int64(0)
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		offset = err.Offset
+	case *json.UnmarshalTypeError:
+		offset = err.Offset
+	}
+	if offset < 0 || offset > int64(len(w.raw)) {
+		offset = 0
+	}
+	return &positionedError{
+		in:     w.raw,
+		offset: int(offset),
+		err:    err,
+	}
+}
+
+// Build constructs the Widget
+func (w *WidgetConfig) Build() (Widget, error) {
+	registryLock.Lock()
+	constructor, ok := registry[w.Type]
+	registryLock.Unlock()
+	if !ok {
+		return nil, w.Errorf("unknown widget type %q", w.Type)
+	}
+
+	widget, err := constructor(w)
+	if err != nil {
+		if _, ok := err.(*positionedError); !ok {
+			err = w.Errorf("%v: %v", w.Type, err)
+		}
+		return nil, err
+	}
+
+	overrides := blockOverrides{}
+	err = w.Decode(&overrides)
+	if err != nil {
+		return nil, err
+	}
+	if !overrides.empty() {
+		widget = &Edit{
+			Widget: widget,
+			Func:   overrides.apply,
+		}
+	}
+	return widget, nil
+}
+
+// BuildAll builds every Widget in configs
+func BuildAll(configs []*WidgetConfig) ([]Widget, error) {
+	widgets := make([]Widget, len(configs))
+	for i, wc := range configs {
+		w, err := wc.Build()
+		if err != nil {
+			return nil, err
+		}
+		widgets[i] = w
+	}
+	return widgets, nil
+}
+
+// positionedError is an error at offset bytes into the text of a widget
+type positionedError struct {
+	in     []byte
+	offset int
+	err    error
+}
+
+func (e *positionedError) Error() string {
+	return e.err.Error()
+}
+
+// A ConfigError is an error in a config file
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+// newConfigError positions err within data, which was read from file
+func newConfigError(file string, data []byte, err error) error {
+	offset := -1
+	switch perr := err.(type) {
+	case *positionedError:
+		err = perr.err
+		// identical widgets fail identically, so the first match is as good
+		// as any
+		offset = bytes.Index(data, perr.in)
+		if offset != -1 {
+			offset += perr.offset
+		}
+	case *json.SyntaxError:
+		offset = int(perr.Offset)
+	case *json.UnmarshalTypeError:
+		offset = int(perr.Offset)
+	}
+	cerr := &ConfigError{
+		File: file,
+		Err:  err,
+	}
+	if offset < 0 || offset > len(data) {
+		return cerr
+	}
+	cerr.Line = 1
+	cerr.Column = 1
+	for _, b := range data[:offset] {
+		cerr.Column++
+		if b == '\n' {
+			cerr.Line++
+			cerr.Column = 1
+		}
+	}
+	return cerr
+}
+
+// fileConfig is the top level object of a config file
+type fileConfig struct {
+	Interval          duration        `json:"interval"`
+	Timeout           duration        `json:"timeout"`
+	MinUpdateInterval duration        `json:"min_update_interval"`
+	KeepAlive         duration        `json:"keep_alive"`
+	StaleColor        *jsonColor      `json:"stale_color"`
+	StopSignal        int             `json:"stop_signal"`
+	ContSignal        int             `json:"cont_signal"`
+	DontWatchBinary   bool            `json:"dont_watch_binary"`
+	Supervise         bool            `json:"supervise"`
+	ControlSocket     string          `json:"control_socket"`
+	Separator         *bool           `json:"separator"`
+	SeparatorWidth    *int            `json:"separator_block_width"`
+	Widgets           []*WidgetConfig `json:"widgets"`
+
+	// raw is the text of the file, if it was JSON
+	raw []byte
+}
+
+// errorf returns an error positioned at the value of the top level key
+func (fc *fileConfig) errorf(key, format string, args ...interface{}) error {
+	offset := topLevelKey(fc.raw, key)
+	if offset == -1 {
+		offset = 0
+	}
+	return &positionedError{
+		in:     fc.raw,
+		offset: offset,
+		err:    fmt.Errorf(format, args...),
+	}
+}
+
+// validate checks the top level settings. A zero duration means the default,
+// but an interval which is set must be positive
+func (fc *fileConfig) validate() error {
+	if fc.Interval < 0 || (fc.Interval == 0 && topLevelKey(fc.raw, "interval") != -1) {
+		return fc.errorf("interval", "interval must be positive, not %v", time.Duration(fc.Interval))
+	}
+	durations := []struct {
+		key   string
+		value duration
+	}{
+		{"timeout", fc.Timeout},
+		{"min_update_interval", fc.MinUpdateInterval},
+		{"keep_alive", fc.KeepAlive},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			return fc.errorf(d.key, "%v must not be negative, not %v", d.key, time.Duration(d.value))
+		}
+	}
+	return nil
+}
+
+// positionTopLevel positions an error decoding data into a fileConfig at the
+// top level value which caused it. encoding/json only positions syntax and
+// type errors itself, and not errors from UnmarshalJSON methods such as a
+// duration's
+func positionTopLevel(data []byte, err error) error {
+	switch err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError, *positionedError:
+		return err
+	}
+	values := map[string]json.RawMessage{}
+	if json.Unmarshal(data, &values) != nil {
+		return err
+	}
+	// json stops at the first bad value, so the earliest is the one
+	key, offset := "", -1
+	for k, value := range values {
+		single, merr := json.Marshal(map[string]json.RawMessage{k: value})
+		if merr != nil || json.Unmarshal(single, &fileConfig{}) == nil {
+			continue
+		}
+		o := topLevelKey(data, k)
+		if o != -1 && (offset == -1 || o < offset) {
+			key, offset = k, o
+		}
+	}
+	if offset == -1 {
+		return err
+	}
+	return &positionedError{
+		in:     data,
+		offset: offset,
+		err:    fmt.Errorf("%v: %v", key, err),
+	}
+}
+
+// topLevelKey returns the offset of the value of key in the JSON object data,
+// ignoring keys of nested objects, or -1 if it is not set
+func topLevelKey(data []byte, key string) int {
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case '"':
+			end := i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(data) {
+				return -1
+			}
+			if depth == 1 && string(data[i+1:end]) == key {
+				rest := bytes.TrimLeft(data[end+1:], " \t\r\n")
+				if len(rest) > 0 && rest[0] == ':' {
+					value := bytes.TrimLeft(rest[1:], " \t\r\n")
+					return len(data) - len(value)
+				}
+			}
+			i = end
+		}
+	}
+	return -1
+}
+
+// LoadConfig builds a Config from a JSON file, such as
+//
+//	{
+//		"interval": "1s",
+//		"separator": false,
+//		"separator_block_width": 24,
+//		"widgets": [
+//			{"type": "cpu", "colors": "htop", "short_interval": "5s", "show_1": true},
+//			{"type": "memory"},
+//			{"type": "time", "format": "Mon 15:04", "name": "clock"}
+//		]
+//	}
+//
+// Errors are returned as a *ConfigError. The returned Config's ConfigFile is
+// set to path, so Loop reloads it when it changes
+func LoadConfig(path string) (Config, error) {
+	return loadConfig(path, func(int, *WidgetConfig) Widget {
+		return nil
+	})
+}
+
+// loadConfig is LoadConfig, but calls reuse for each top level widget before
+// building it. If reuse returns a Widget it is used instead of building a new
+// one
+func loadConfig(path string, reuse func(index int, wc *WidgetConfig) Widget) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	fc := fileConfig{raw: data}
+	err = json.Unmarshal(data, &fc)
+	if err != nil {
+		return Config{}, newConfigError(path, data, positionTopLevel(data, err))
+	}
+	return fc.build(path, reuse, func(index int, err error) error {
+		return newConfigError(path, data, err)
+	})
+}
+
+// build builds the Config described by fc, which was read from path. reuse is
+// as for loadConfig, and wrap positions an error building the index'th widget,
+// or an error in the top level settings if index is -1
+func (fc *fileConfig) build(path string, reuse func(index int, wc *WidgetConfig) Widget, wrap func(index int, err error) error) (Config, error) {
+	err := fc.validate()
+	if err != nil {
+		return Config{}, wrap(-1, err)
+	}
+
+	widgets := make([]Widget, len(fc.Widgets))
+	for i, wc := range fc.Widgets {
+		widgets[i] = reuse(i, wc)
+		if widgets[i] != nil {
+			continue
+		}
+		var err error
+		widgets[i], err = wc.Build()
+		if err != nil {
+			return Config{}, wrap(i, err)
+		}
+	}
+
+	c := Config{
+		Widgets:           widgets,
+		DontWatchBinary:   fc.DontWatchBinary,
+		Supervise:         fc.Supervise,
+		ControlSocket:     fc.ControlSocket,
+		Interval:          time.Duration(fc.Interval),
+		Timeout:           time.Duration(fc.Timeout),
+		MinUpdateInterval: time.Duration(fc.MinUpdateInterval),
+		KeepAlive:         time.Duration(fc.KeepAlive),
+		StopSignal:        syscall.Signal(fc.StopSignal),
+		ContSignal:        syscall.Signal(fc.ContSignal),
+		ConfigFile:        path,
+		widgetConfigs:     fc.Widgets,
+	}
+	if fc.StaleColor != nil {
+		c.StaleColor = fc.StaleColor.Color
+	}
+	if fc.Separator != nil {
+		c.DefaultSeparator.Hide = BoolPtr(!*fc.Separator)
+	}
+	c.DefaultSeparator.Width = fc.SeparatorWidth
+	return c, nil
+}
+
+// widgetKeys returns a string for each Widget which is equal for Widgets with
+// the same configuration, or nil if c was not loaded from a file
+func (c *Config) widgetKeys() []string {
+	if len(c.widgetConfigs) != len(c.Widgets) {
+		// Widgets was changed after loading
+		return nil
+	}
+	keys := make([]string, len(c.widgetConfigs))
+	for i, wc := range c.widgetConfigs {
+		keys[i] = wc.key()
+	}
+	return keys
+}
+
+// blockOverrides are the keys that can be set on any widget to override fields
+// of it's blocks. They use the same names as the i3bar protocol
+type blockOverrides struct {
+	Name           *string    `json:"name"`
+	Instance       *string    `json:"instance"`
+	Color          *jsonColor `json:"color"`
+	Background     *jsonColor `json:"background"`
+	Border         *jsonColor `json:"border"`
+	BorderTop      *int       `json:"border_top"`
+	BorderRight    *int       `json:"border_right"`
+	BorderBottom   *int       `json:"border_bottom"`
+	BorderLeft     *int       `json:"border_left"`
+	MinWidth       *minWidth  `json:"min_width"`
+	Align          *Alignment `json:"align"`
+	Urgent         *bool      `json:"urgent"`
+	Markup         *Markup    `json:"markup"`
+	Separator      *bool      `json:"separator"`
+	SeparatorWidth *int       `json:"separator_block_width"`
+}
+
+func (o *blockOverrides) empty() bool {
+	return *o == blockOverrides{}
+}
+
+func (o *blockOverrides) apply(s *StatusBlock) {
+	if o.Name != nil {
+		s.Name = *o.Name
+	}
+	if o.Instance != nil {
+		s.Instance = *o.Instance
+	}
+	if o.Color != nil {
+		s.Color = o.Color.Color
+	}
+	if o.Background != nil {
+		s.Background = o.Background.Color
+	}
+	if o.Border != nil {
+		s.Border = o.Border.Color
+	}
+	if o.BorderTop != nil {
+		s.BorderTop = o.BorderTop
+	}
+	if o.BorderRight != nil {
+		s.BorderRight = o.BorderRight
+	}
+	if o.BorderBottom != nil {
+		s.BorderBottom = o.BorderBottom
+	}
+	if o.BorderLeft != nil {
+		s.BorderLeft = o.BorderLeft
+	}
+	if o.MinWidth != nil {
+		s.MinWidth = o.MinWidth.Pixels
+		s.MinWidthText = o.MinWidth.Text
+	}
+	if o.Align != nil {
+		s.Align = *o.Align
+	}
+	if o.Urgent != nil {
+		s.Urgent = *o.Urgent
+	}
+	if o.Markup != nil {
+		s.Markup = *o.Markup
+	}
+	if o.Separator != nil {
+		s.Separator.Hide = BoolPtr(!*o.Separator)
+	}
+	if o.SeparatorWidth != nil {
+		s.Separator.Width = o.SeparatorWidth
+	}
+}
+
+// duration is a time.Duration in JSON, either as a string such as "1.5s", or
+// a number of seconds
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case string:
+		pd, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = duration(pd)
+	case float64:
+		*d = duration(v * float64(time.Second))
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	return nil
+}
+
+// jsonColor is a color.Color in JSON, as a "#RRGGBB" string
+type jsonColor struct {
+	color.Color
+}
+
+func (c *jsonColor) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	c.Color, err = parseColor(s)
+	return err
+}
+
+// minWidth is a min_width in JSON, which is either a number of pixels or a
+// string
+type minWidth struct {
+	Pixels int
+	Text   string
+}
+
+func (m *minWidth) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &m.Text)
+	}
+	v, err := strconv.Atoi(string(b))
+	if err != nil {
+		return fmt.Errorf("invalid min_width %s", b)
+	}
+	m.Pixels = v
+	return nil
+}
@@ -0,0 +1,61 @@
+package my3status
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigErrorPositions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "my3status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	tests := []struct {
+		config string
+		line   int
+		column int
+	}{
+		{"{\n\t\"interval\": \"-1s\",\n\t\"widgets\": []\n}", 2, 14},
+		{"{\n\t\"interval\": 0,\n\t\"widgets\": []\n}", 2, 14},
+		{"{\n\t\"widgets\": [{\"type\": \"time\", \"timeout\": \"1s\"}],\n\t\"timeout\": \"-1s\"\n}", 3, 13},
+		{"{\"min_update_interval\": -1, \"widgets\": []}", 1, 25},
+		{"{\"keep_alive\": \"-5m\", \"widgets\": []}", 1, 16},
+		// errors from UnmarshalJSON methods
+		{"{\n\t\"widgets\": [],\n\t\"interval\": \"1x\"\n}", 3, 14},
+		{"{\"interval\": true, \"widgets\": []}", 1, 14},
+		{"{\"widgets\": [], \"stale_color\": \"red\", \"timeout\": \"1y\"}", 1, 32},
+	}
+	for _, test := range tests {
+		err := ioutil.WriteFile(path, []byte(test.config), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = LoadConfig(path)
+		cerr, ok := err.(*ConfigError)
+		if !ok {
+			t.Errorf("%q: expected a *ConfigError, got %v", test.config, err)
+			continue
+		}
+		if cerr.Line != test.line || cerr.Column != test.column {
+			t.Errorf("%q: error at %v:%v, expected %v:%v: %v", test.config, cerr.Line, cerr.Column, test.line, test.column, err)
+		}
+	}
+
+	err = ioutil.WriteFile(path, []byte(`{"timeout": 0, "keep_alive": "1m", "widgets": []}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Interval != 0 || c.KeepAlive != time.Minute {
+		t.Errorf("got interval %v and keep_alive %v", c.Interval, c.KeepAlive)
+	}
+}
@@ -0,0 +1,169 @@
+package my3status
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Commands accepted by the control socket
+const (
+	// ControlList returns the blocks in the last frame
+	ControlList = "list"
+
+	// ControlRefresh redraws the bar immediately
+	ControlRefresh = "refresh"
+
+	// ControlClick sends a ClickEvent to the block with Name and Instance. If
+	// Instance is unset the first block with Name is clicked
+	ControlClick = "click"
+
+	// ControlRestart Restarts the bar
+	ControlRestart = "restart"
+
+	// ControlPost posts Message to the Message widget with Name. If Name is
+	// unset "message" is used
+	ControlPost = "post"
+)
+
+// A ControlRequest is a command sent to the control socket. Requests and
+// responses are sent as one JSON object per line
+type ControlRequest struct {
+	Command  string `json:"command"`
+	Name     string `json:"name,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Button   int    `json:"button,omitempty"`
+
+	Message *PostedMessage `json:"message,omitempty"`
+}
+
+// A ControlResponse is the reply to a ControlRequest
+type ControlResponse struct {
+	Error string `json:"error,omitempty"`
+
+	// Blocks are the blocks returned by ControlList, in the i3bar protocol's
+	// format
+	Blocks []json.RawMessage `json:"blocks,omitempty"`
+}
+
+// DefaultControlSocket returns the path of the control socket used if
+// Config.ControlSocket is "default"
+func DefaultControlSocket() string {
+	return filepath.Join(runtimeDir(), "my3status.sock")
+}
+
+// Control sends req to a bar listening on socket, returning it's response.
+// Errors reported by the bar are returned as errors
+func Control(socket string, req ControlRequest) (ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return ControlResponse{}, fmt.Errorf("unable to connect to my3status: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ControlResponse{}, err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	if err != nil {
+		return ControlResponse{}, err
+	}
+
+	resp := ControlResponse{}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return resp, fmt.Errorf("unable to read response: %v", err)
+	}
+	err = json.Unmarshal(line, &resp)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("%v", resp.Error)
+	}
+	return resp, nil
+}
+
+// controlRequest is a ControlRequest waiting to be handled by the render
+// goroutine, which writes the response to conn
+type controlRequest struct {
+	ControlRequest
+	conn      net.Conn
+	responded chan struct{}
+}
+
+func (r *controlRequest) respond(resp ControlResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(ControlResponse{Error: err.Error()})
+	}
+	r.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	r.conn.Write(append(data, '\n'))
+	close(r.responded)
+}
+
+// listenControl listens on the unix socket at path, sending requests to
+// requests until done is closed. Any stale socket at path is removed
+func listenControl(path string, requests chan<- *controlRequest, done <-chan struct{}) (net.Listener, error) {
+	if path == "default" {
+		path = DefaultControlSocket()
+	}
+	// a socket left by a crashed or Restarted process refuses connections
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%v is in use by another process", path)
+	}
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveControl(conn, requests, done)
+		}
+	}()
+	return l, nil
+}
+
+func serveControl(conn net.Conn, requests chan<- *controlRequest, done <-chan struct{}) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		req := &controlRequest{
+			conn:      conn,
+			responded: make(chan struct{}),
+		}
+		err = json.Unmarshal(line, &req.ControlRequest)
+		if err != nil {
+			req.respond(ControlResponse{Error: err.Error()})
+			continue
+		}
+		select {
+		case requests <- req:
+		case <-done:
+			return
+		}
+		// the connection must stay open until the response is written
+		select {
+		case <-req.responded:
+		case <-done:
+			return
+		}
+	}
+}
@@ -2,11 +2,11 @@ package my3status
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"sort"
 	"strconv"
-	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type CPUColors struct {
@@ -52,6 +52,17 @@ type CPU struct {
 
 	stat    ProcFile
 	loadavg ProcFile
+
+	// free holds samples which have left the short interval, to be reused
+	free *statSample
+
+	// buffers reused between calls to Status
+	runes    []rune
+	segments []colorSegment
+	segPtrs  []int
+	times    []int64
+	scratch  []byte
+	text     textBuffer
 }
 
 func (c *CPU) Status() (StatusBlock, error) {
@@ -77,19 +88,24 @@ func (c *CPU) Status() (StatusBlock, error) {
 		return StatusBlock{}, fmt.Errorf("CPU: enable Show{1,5,15} and/or set ShortInterval")
 	}
 
-	runes := make([]rune, 0, minlen)
+	runes := c.runes[:0]
 	writtenSegs := 0
 	front := (minlen - tml) / (segments * 2)
 	runes = pad(runes, front, false)
 
-	colorSegments := make([]colorSegment, 0, 10)
+	colorSegments := c.segments[:0]
 	totalColorShares := int64(0)
 
 	if c.ShortInterval != 0 || c.Colors != nil {
 		now := time.Now()
-		newSample := &statSample{
-			Time: now,
+		newSample := c.free
+		if newSample != nil {
+			c.free = newSample.Next
+			newSample.Next = nil
+		} else {
+			newSample = &statSample{}
 		}
+		newSample.Time = now
 
 		cpus := 0
 		var times []int64
@@ -107,11 +123,21 @@ func (c *CPU) Status() (StatusBlock, error) {
 				}
 				remStat = remStat[idx+1:]
 			}
-			statLine := string(stat[5:endCpuAll])
-			stats := strings.Split(statLine, " ")
-			parts := make([]int64, len(stats))
-			for i := range stats {
-				parts[i], err = strconv.ParseInt(stats[i], 10, 64)
+			statLine := stat[5:endCpuAll]
+			parts := newSample.Stats[:0]
+			for {
+				field := statLine
+				space := bytes.IndexByte(statLine, ' ')
+				if space != -1 {
+					field = statLine[:space]
+				}
+				// fields which do not parse are counted as 0
+				v, _ := parseUint(field)
+				parts = append(parts, int64(v))
+				if space == -1 {
+					break
+				}
+				statLine = statLine[space+1:]
 			}
 			newSample.Stats = parts
 		}
@@ -125,16 +151,17 @@ func (c *CPU) Status() (StatusBlock, error) {
 		if c.oldSample == nil {
 			c.oldSample = c.newSample
 		}
-		s := c.oldSample
-		for ; s != nil && s.Time.Before(oldTime); s = s.Next {
+		for c.oldSample != c.newSample && c.oldSample.Time.Before(oldTime) {
+			old := c.oldSample
+			c.oldSample = old.Next
+			old.Next = c.free
+			c.free = old
 		}
-		c.oldSample = s
-		if times == nil {
-			times = make([]int64, len(c.newSample.Stats))
-		}
-		for i := range times {
-			times[i] = c.newSample.Stats[i] - c.oldSample.Stats[i]
+		times = c.times[:0]
+		for i := range c.newSample.Stats {
+			times = append(times, c.newSample.Stats[i]-c.oldSample.Stats[i])
 		}
+		c.times = times
 
 		allTime := int64(0)
 		for _, s := range times {
@@ -142,8 +169,12 @@ func (c *CPU) Status() (StatusBlock, error) {
 		}
 
 		if c.ShortInterval != 0 {
-			immLA := (float64(allTime-times[3]) * float64(cpus)) / float64(allTime)
-			runes = append(runes, []rune(fmt.Sprintf("%.2f", immLA))...)
+			immLA := 0.0
+			if allTime > 0 {
+				immLA = (float64(allTime-times[3]) * float64(cpus)) / float64(allTime)
+			}
+			c.scratch = strconv.AppendFloat(c.scratch[:0], immLA, 'f', 2, 64)
+			runes = appendRunes(runes, c.scratch)
 			writtenSegs++
 			runes = pad(runes, front+(minlen*writtenSegs)/segments, true)
 		}
@@ -189,41 +220,57 @@ func (c *CPU) Status() (StatusBlock, error) {
 			return StatusBlock{}, err
 		}
 
-		las := strings.SplitN(string(loadavg), " ", 4)
+		var las [3][]byte
+		rest := loadavg
+		for i := range las {
+			space := bytes.IndexByte(rest, ' ')
+			if space == -1 {
+				las[i], rest = rest, nil
+				continue
+			}
+			las[i], rest = rest[:space], rest[space+1:]
+		}
 
 		if c.Show1 {
-			runes = append(runes, []rune(las[0])...)
+			runes = appendRunes(runes, las[0])
 			writtenSegs++
 			runes = pad(runes, front+(minlen*writtenSegs)/segments, true)
 		}
 
 		if c.Show5 {
-			runes = append(runes, []rune(las[1])...)
+			runes = appendRunes(runes, las[1])
 			writtenSegs++
 			runes = pad(runes, front+(minlen*writtenSegs)/segments, true)
 		}
 
 		if c.Show15 {
-			runes = append(runes, []rune(las[2])...)
+			runes = appendRunes(runes, las[2])
 			writtenSegs++
 		}
 	}
 	runes = pad(runes, minlen, false)
+	c.runes = runes
+	c.segments = colorSegments
 
 	if totalColorShares <= 0 {
+		c.text.buf = appendUTF8(c.text.buf[:0], runes)
 		return StatusBlock{
-			FullText: string(runes),
+			FullText: c.text.String(),
 		}, nil
 	}
 
-	segPtrs := make([]int, len(colorSegments))
-	for i := range segPtrs {
-		segPtrs[i] = i
+	segPtrs := c.segPtrs[:0]
+	for i := range colorSegments {
+		segPtrs = append(segPtrs, i)
 	}
+	c.segPtrs = segPtrs
 
-	sort.SliceStable(segPtrs, func(ii, ji int) bool {
-		return colorSegments[segPtrs[ii]].Shares < colorSegments[segPtrs[ji]].Shares
-	})
+	// an insertion sort is stable, and there are only a few segments
+	for i := 1; i < len(segPtrs); i++ {
+		for j := i; j > 0 && colorSegments[segPtrs[j]].Shares < colorSegments[segPtrs[j-1]].Shares; j-- {
+			segPtrs[j], segPtrs[j-1] = segPtrs[j-1], segPtrs[j]
+		}
+	}
 
 	unallocedRunes := int64(len(runes))
 	for _, sp := range segPtrs {
@@ -238,23 +285,101 @@ func (c *CPU) Status() (StatusBlock, error) {
 		unallocedRunes -= rs
 	}
 
-	buf := &bytes.Buffer{}
+	buf := c.text.buf[:0]
 	for _, seg := range colorSegments {
 		if seg.Runes <= 0 {
 			continue
 		}
-		fmt.Fprintf(buf, `<span %s>%s</span>`, seg.Color, string(runes[:seg.Runes]))
+		buf = appendSpan(buf, seg.Color, runes[:seg.Runes])
 		runes = runes[seg.Runes:]
 	}
 	if len(runes) > 0 {
-		fmt.Fprintf(buf, `<span %s>%s</span>`, c.Colors.Other, string(runes))
+		buf = appendSpan(buf, c.Colors.Other, runes)
 	}
+	c.text.buf = buf
 	return StatusBlock{
-		FullText: buf.String(),
+		FullText: c.text.String(),
 		Markup:   MarkupPango,
 	}, nil
 }
 
+// appendRunes appends the runes of the UTF-8 text b to runes
+func appendRunes(runes []rune, b []byte) []rune {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		runes = append(runes, r)
+		b = b[size:]
+	}
+	return runes
+}
+
+// appendUTF8 appends runes to dst as UTF-8
+func appendUTF8(dst []byte, runes []rune) []byte {
+	var enc [utf8.UTFMax]byte
+	for _, r := range runes {
+		n := utf8.EncodeRune(enc[:], r)
+		dst = append(dst, enc[:n]...)
+	}
+	return dst
+}
+
+// appendSpan appends runes to dst in a pango span with attrs
+func appendSpan(dst []byte, attrs string, runes []rune) []byte {
+	dst = append(dst, "<span "...)
+	dst = append(dst, attrs...)
+	dst = append(dst, '>')
+	dst = appendUTF8(dst, runes)
+	return append(dst, "</span>"...)
+}
+
+func (c *CPU) Pause() {
+}
+
+// Resume drops the sample history, so the time spent paused is not reported as
+// a single sample
+func (c *CPU) Resume() {
+	c.oldSample = nil
+	c.newSample = nil
+}
+
+type cpuSample struct {
+	Stats []int64
+	Time  time.Time
+}
+
+// SaveState saves the sample history, so the short interval counter does not
+// have to start over
+func (c *CPU) SaveState() ([]byte, error) {
+	var samples []cpuSample
+	for s := c.oldSample; s != nil; s = s.Next {
+		samples = append(samples, cpuSample{s.Stats, s.Time})
+	}
+	return json.Marshal(samples)
+}
+
+func (c *CPU) RestoreState(data []byte) error {
+	var samples []cpuSample
+	err := json.Unmarshal(data, &samples)
+	if err != nil {
+		return err
+	}
+	c.oldSample = nil
+	c.newSample = nil
+	for _, s := range samples {
+		sample := &statSample{
+			Stats: s.Stats,
+			Time:  s.Time,
+		}
+		if c.newSample != nil {
+			c.newSample.Next = sample
+		} else {
+			c.oldSample = sample
+		}
+		c.newSample = sample
+	}
+	return nil
+}
+
 func pad(arr []rune, count int, min bool) []rune {
 	if min {
 		arr = append(arr, ' ')
@@ -0,0 +1,84 @@
+package my3status
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+)
+
+// UnmarshalJSON decodes a block in the i3bar protocol's format. Keys which are
+// not part of the protocol are stored in Extra
+func (s *StatusBlock) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	*s = StatusBlock{}
+	for key, value := range fields {
+		var err error
+		switch key {
+		case "full_text":
+			err = json.Unmarshal(value, &s.FullText)
+		case "short_text":
+			err = json.Unmarshal(value, &s.ShortText)
+		case "color":
+			s.Color, err = unmarshalColor(value)
+		case "background":
+			s.Background, err = unmarshalColor(value)
+		case "border":
+			s.Border, err = unmarshalColor(value)
+		case "border_top":
+			err = json.Unmarshal(value, &s.BorderTop)
+		case "border_right":
+			err = json.Unmarshal(value, &s.BorderRight)
+		case "border_bottom":
+			err = json.Unmarshal(value, &s.BorderBottom)
+		case "border_left":
+			err = json.Unmarshal(value, &s.BorderLeft)
+		case "min_width":
+			mw := minWidth{}
+			err = json.Unmarshal(value, &mw)
+			s.MinWidth, s.MinWidthText = mw.Pixels, mw.Text
+		case "align":
+			err = json.Unmarshal(value, &s.Align)
+		case "urgent":
+			err = json.Unmarshal(value, &s.Urgent)
+		case "markup":
+			err = json.Unmarshal(value, &s.Markup)
+		case "separator":
+			var draw *bool
+			err = json.Unmarshal(value, &draw)
+			if draw != nil {
+				s.Separator.Hide = BoolPtr(!*draw)
+			}
+		case "separator_block_width":
+			err = json.Unmarshal(value, &s.Separator.Width)
+		case "name":
+			err = json.Unmarshal(value, &s.Name)
+		case "instance":
+			err = json.Unmarshal(value, &s.Instance)
+		default:
+			var v interface{}
+			err = json.Unmarshal(value, &v)
+			if s.Extra == nil {
+				s.Extra = map[string]interface{}{}
+			}
+			s.Extra[key] = v
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func unmarshalColor(value []byte) (color.Color, error) {
+	if string(value) == "null" {
+		return nil, nil
+	}
+	c := jsonColor{}
+	err := json.Unmarshal(value, &c)
+	return c.Color, err
+}
@@ -0,0 +1,145 @@
+package my3status
+
+import (
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Dzen renders blocks in dzen2's format. dzen2 can only run shell commands when
+// a block is clicked, so clicks are only reported if ClickCommand is set
+type Dzen struct {
+	// Separator is drawn between blocks which do not hide their separator. If
+	// unset "|" is used
+	Separator string
+
+	// The colors used for urgent blocks. If unset white on red is used
+	UrgentColor      color.Color
+	UrgentBackground color.Color
+
+	// ClickCommand is run by dzen2 when a block is clicked, with the first "%s"
+	// replaced by a line which must be written to my3status' stdin, for example
+	//
+	//	echo %s > /tmp/my3status
+	ClickCommand string
+}
+
+// dzenButtons are the buttons which are bound to ClickCommand
+var dzenButtons = []int{ButtonLeft, ButtonMiddle, ButtonRight, ButtonScrollUp, ButtonScrollDown}
+
+func (d Dzen) AppendHeader(dst []byte, c Config) []byte {
+	return dst
+}
+
+func (d Dzen) AppendFrame(dst []byte, blocks []StatusBlock) []byte {
+	separator := d.Separator
+	if separator == "" {
+		separator = "|"
+	}
+
+	var prev *StatusBlock
+	for i, s := range blocks {
+		if s.FullText == "" {
+			continue
+		}
+		if prev != nil {
+			left, right, draw := separatorGap(*prev)
+			if draw {
+				dst = appendDzenOffset(dst, left)
+				dst = appendDzenText(dst, separator)
+				dst = appendDzenOffset(dst, right)
+			} else {
+				dst = appendDzenOffset(dst, left+right)
+			}
+		}
+		prev = &blocks[i]
+		dst = d.appendBlock(dst, s)
+	}
+	return append(dst, '\n')
+}
+
+func (d Dzen) appendBlock(dst []byte, s StatusBlock) []byte {
+	if d.ClickCommand != "" {
+		for _, button := range dzenButtons {
+			dst = append(dst, "^ca("...)
+			dst = strconv.AppendInt(dst, int64(button), 10)
+			dst = append(dst, ", "...)
+			dst = append(dst, strings.Replace(d.ClickCommand, "%s", clickAction(button, s), 1)...)
+			dst = append(dst, ')')
+		}
+	}
+
+	fg, bg := s.Color, s.Background
+	if s.Urgent {
+		fg, bg = d.UrgentColor, d.UrgentBackground
+		if fg == nil {
+			fg = color.White
+		}
+		if bg == nil {
+			bg = color.RGBA{R: 0xFF, A: 0xFF}
+		}
+	}
+	dst = appendDzenColor(dst, "fg", fg)
+	dst = appendDzenColor(dst, "bg", bg)
+
+	if s.Markup == MarkupPango {
+		for _, span := range parsePango(s.FullText) {
+			if span.Foreground != nil {
+				dst = appendDzenColor(dst, "fg", span.Foreground)
+			}
+			if span.Background != nil {
+				dst = appendDzenColor(dst, "bg", span.Background)
+			}
+			dst = appendDzenText(dst, span.Text)
+			if span.Foreground != nil {
+				dst = appendDzenColor(dst, "fg", fg)
+			}
+			if span.Background != nil {
+				dst = appendDzenColor(dst, "bg", bg)
+			}
+		}
+	} else {
+		dst = appendDzenText(dst, s.FullText)
+	}
+
+	dst = append(dst, "^fg()^bg()"...)
+	if d.ClickCommand != "" {
+		for range dzenButtons {
+			dst = append(dst, "^ca()"...)
+		}
+	}
+	return dst
+}
+
+// appendDzenColor appends a color command, such as ^fg(#RRGGBB), or resets the
+// color if c is nil
+func appendDzenColor(dst []byte, command string, c color.Color) []byte {
+	dst = append(dst, '^')
+	dst = append(dst, command...)
+	dst = append(dst, '(')
+	if c != nil {
+		dst = appendHexColor(dst, opaque(c))
+	}
+	return append(dst, ')')
+}
+
+func appendDzenOffset(dst []byte, pixels int) []byte {
+	dst = append(dst, "^p(+"...)
+	dst = strconv.AppendInt(dst, int64(pixels), 10)
+	return append(dst, ')')
+}
+
+func appendDzenText(dst []byte, text string) []byte {
+	text = strings.Replace(text, "\n", " ", -1)
+	return append(dst, strings.Replace(text, "^", "^^", -1)...)
+}
+
+func (d Dzen) AppendFooter(dst []byte) []byte {
+	return dst
+}
+
+// ReadClicks reads the lines written by ClickCommand
+func (d Dzen) ReadClicks(in io.Reader, fn func(ClickEvent)) error {
+	return readClickActions(in, fn)
+}
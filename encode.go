@@ -0,0 +1,213 @@
+package my3status
+
+import (
+	"encoding/json"
+	"image/color"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// MarshalJSON encodes s in the i3bar protocol's format
+func (s StatusBlock) MarshalJSON() ([]byte, error) {
+	return appendBlock(nil, s), nil
+}
+
+// appendBlock appends the i3bar representation of s to dst
+func appendBlock(dst []byte, s StatusBlock) []byte {
+	dst = append(dst, `{"name":`...)
+	dst = appendString(dst, s.Name)
+	dst = append(dst, `,"instance":`...)
+	dst = appendString(dst, s.Instance)
+	dst = append(dst, `,"full_text":`...)
+	dst = appendString(dst, s.FullText)
+	if s.ShortText != "" {
+		dst = append(dst, `,"short_text":`...)
+		dst = appendString(dst, s.ShortText)
+	}
+	if s.Color != nil {
+		dst = append(dst, `,"color":`...)
+		dst = appendColor(dst, s.Color)
+	}
+	if s.Background != nil {
+		dst = append(dst, `,"background":`...)
+		dst = appendColor(dst, s.Background)
+	}
+	if s.Border != nil {
+		dst = append(dst, `,"border":`...)
+		dst = appendColor(dst, s.Border)
+	}
+	if s.BorderTop != nil {
+		dst = append(dst, `,"border_top":`...)
+		dst = strconv.AppendInt(dst, int64(*s.BorderTop), 10)
+	}
+	if s.BorderRight != nil {
+		dst = append(dst, `,"border_right":`...)
+		dst = strconv.AppendInt(dst, int64(*s.BorderRight), 10)
+	}
+	if s.BorderBottom != nil {
+		dst = append(dst, `,"border_bottom":`...)
+		dst = strconv.AppendInt(dst, int64(*s.BorderBottom), 10)
+	}
+	if s.BorderLeft != nil {
+		dst = append(dst, `,"border_left":`...)
+		dst = strconv.AppendInt(dst, int64(*s.BorderLeft), 10)
+	}
+	if s.MinWidthText != "" {
+		dst = append(dst, `,"min_width":`...)
+		dst = appendString(dst, s.MinWidthText)
+	} else if s.MinWidth != 0 {
+		dst = append(dst, `,"min_width":`...)
+		dst = strconv.AppendInt(dst, int64(s.MinWidth), 10)
+	}
+	if s.Align != "" && s.Align != AlignLeft {
+		dst = append(dst, `,"align":`...)
+		dst = appendString(dst, string(s.Align))
+	}
+	if s.Urgent {
+		dst = append(dst, `,"urgent":true`...)
+	}
+
+	if s.Separator.Hide != nil {
+		dst = append(dst, `,"separator":`...)
+		dst = strconv.AppendBool(dst, !*s.Separator.Hide)
+	}
+	if s.Separator.Width != nil {
+		dst = append(dst, `,"separator_block_width":`...)
+		dst = strconv.AppendInt(dst, int64(*s.Separator.Width), 10)
+	}
+
+	if s.Markup != "" && s.Markup != MarkupNone {
+		dst = append(dst, `,"markup":`...)
+		dst = appendString(dst, string(s.Markup))
+	}
+
+	if len(s.Extra) > 0 {
+		dst = appendExtra(dst, s.Extra)
+	}
+	return append(dst, '}')
+}
+
+// blockKeys are the keys written by appendBlock, which cannot be overridden
+// by StatusBlock.Extra
+var blockKeys = map[string]bool{
+	"name":                  true,
+	"instance":              true,
+	"full_text":             true,
+	"short_text":            true,
+	"color":                 true,
+	"background":            true,
+	"border":                true,
+	"border_top":            true,
+	"border_right":          true,
+	"border_bottom":         true,
+	"border_left":           true,
+	"min_width":             true,
+	"align":                 true,
+	"urgent":                true,
+	"separator":             true,
+	"separator_block_width": true,
+	"markup":                true,
+}
+
+// appendExtra appends the keys of extra in sorted order, so identical frames
+// encode identically. Unlike the rest of the encoder this allocates
+func appendExtra(dst []byte, extra map[string]interface{}) []byte {
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		if !blockKeys[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, err := json.Marshal(extra[k])
+		if err != nil {
+			continue
+		}
+		dst = append(dst, ',')
+		dst = appendString(dst, k)
+		dst = append(dst, ':')
+		dst = append(dst, v...)
+	}
+	return dst
+}
+
+const hex = "0123456789ABCDEF"
+
+// appendColor appends c as a JSON string in the form "#RRGGBB", or
+// "#RRGGBBAA" if it is not opaque
+func appendColor(dst []byte, c color.Color) []byte {
+	dst = append(dst, '"')
+	dst = appendHexColor(dst, c)
+	return append(dst, '"')
+}
+
+// appendHexColor appends c in the form #RRGGBB or #RRGGBBAA
+func appendHexColor(dst []byte, c color.Color) []byte {
+	r, g, b, a := c.RGBA()
+	if a != 0xFFFF && a != 0 {
+		// color.Color is alpha-premultiplied
+		r = r * 0xFFFF / a
+		g = g * 0xFFFF / a
+		b = b * 0xFFFF / a
+	}
+	dst = append(dst, '#')
+	for _, v := range [...]uint32{r >> 8, g >> 8, b >> 8} {
+		dst = append(dst, hex[v>>4], hex[v&0xF])
+	}
+	if a != 0xFFFF {
+		a >>= 8
+		dst = append(dst, hex[a>>4], hex[a&0xF])
+	}
+	return dst
+}
+
+// appendString appends s as a quoted JSON string
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
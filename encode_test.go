@@ -0,0 +1,172 @@
+package my3status
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testBlocks() []StatusBlock {
+	return []StatusBlock{
+		{
+			Name:     "cpu",
+			Instance: "1",
+			FullText: `<span foreground="#00FF00">1.00</span> 0.50`,
+			Markup:   MarkupPango,
+		},
+		{
+			Name:      "memory",
+			Instance:  "2",
+			FullText:  "3.2/15.5G",
+			ShortText: "3.2G",
+			Color:     color.NRGBA{R: 0xFF, A: 0xFF},
+			MinWidth:  100,
+			Align:     AlignRight,
+			Urgent:    true,
+			Separator: Separator{
+				Hide:  BoolPtr(true),
+				Width: IntPtr(24),
+			},
+		},
+		{
+			Name:     "clock",
+			Instance: "3.1",
+			FullText: "Mon 15:04 \"UTC\"",
+		},
+	}
+}
+
+func TestAppendFrameAllocs(t *testing.T) {
+	blocks := testBlocks()
+	frame := I3Bar{}.AppendFrame(nil, blocks)
+	allocs := testing.AllocsPerRun(100, func() {
+		frame = I3Bar{}.AppendFrame(frame[:0], blocks)
+	})
+	if allocs != 0 {
+		t.Errorf("AppendFrame made %v allocations, expected 0", allocs)
+	}
+}
+
+func BenchmarkAppendFrame(b *testing.B) {
+	blocks := testBlocks()
+	frame := I3Bar{}.AppendFrame(nil, blocks)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frame = I3Bar{}.AppendFrame(frame[:0], blocks)
+	}
+}
+
+// frameBench renders frames of the built-in widgets the same way Loop does,
+// with the widgets built by the config loader
+type frameBench struct {
+	c      Config
+	states []*widgetState
+	col    *collector
+	routes *clickRoutes
+	blocks []StatusBlock
+	frame  []byte
+	dir    string
+}
+
+func newFrameBench(t testing.TB) *frameBench {
+	dir, err := ioutil.TempDir("", "my3status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp := filepath.Join(dir, "temp1_input")
+	err = ioutil.WriteFile(temp, []byte("42000\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var configs []*WidgetConfig
+	err = json.Unmarshal([]byte(fmt.Sprintf(`[
+		{"type": "cpu", "colors": "htop", "short_interval": "1ms", "show_1": true, "show_15": true, "width": 24},
+		{"type": "memory", "name": "memory"},
+		{"type": "temperature", "path": %q, "divisor": 1000},
+		{"type": "time", "format": "Mon 15:04", "separator_block_width": 8},
+		{"type": "group", "widgets": [
+			{"type": "text", "full_text": "a"},
+			{"type": "text", "full_text": "b", "color": "#FF0000"}
+		]}
+	]`, temp)), &configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	widgets, err := BuildAll(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fb := &frameBench{
+		c: Config{
+			Widgets: widgets,
+		}.withDefaults(),
+		routes: &clickRoutes{},
+		dir:    dir,
+	}
+	for _, w := range widgets {
+		fb.states = append(fb.states, newWidgetState(w, "", func() {}))
+	}
+	fb.col = newCollector(fb.states)
+	return fb
+}
+
+func (fb *frameBench) render() {
+	fb.col.collect(time.Second)
+	fb.routes.reset()
+	fb.blocks = fb.c.appendBlocks(fb.blocks[:0], fb.states, fb.routes)
+	fb.frame = fb.c.Renderer.AppendFrame(fb.frame[:0], fb.blocks)
+}
+
+func (fb *frameBench) close() {
+	fb.col.close()
+	closeAll(fb.states)
+	os.RemoveAll(fb.dir)
+}
+
+func TestFrameAllocs(t *testing.T) {
+	fb := newFrameBench(t)
+	defer fb.close()
+	// the first frames fill the buffers and the CPU's sample history
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond)
+		fb.render()
+	}
+	for _, b := range fb.blocks {
+		if b.FullText == "" && b.Name != "" {
+			continue
+		}
+		if len(b.FullText) > 7 && b.FullText[:7] == "error: " {
+			t.Fatalf("%v: %v", b.Name, b.FullText)
+		}
+	}
+	// the CPU keeps it's samples for the short interval, so frames are spaced
+	// out by it to let old samples be reused, as they are in a running bar
+	allocs := testing.AllocsPerRun(100, func() {
+		time.Sleep(time.Millisecond)
+		fb.render()
+	})
+	// the CPU's text changes with every sample, so it's string is allocated
+	// every frame
+	if allocs > 2 {
+		t.Errorf("a frame made %v allocations, expected at most 2", allocs)
+	}
+}
+
+func BenchmarkFrame(b *testing.B) {
+	fb := newFrameBench(b)
+	defer fb.close()
+	fb.render()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fb.render()
+	}
+}
@@ -0,0 +1,520 @@
+package my3status
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ExecMode controls when an Exec runs it's command
+type ExecMode string
+
+const (
+	// ExecInterval runs the command every Interval, or only once if Interval
+	// is unset. It is the default
+	ExecInterval ExecMode = ""
+
+	// ExecOnce runs the command once, and again when the block is clicked
+	ExecOnce ExecMode = "once"
+
+	// ExecRepeat runs the command again as soon as it exits
+	ExecRepeat ExecMode = "repeat"
+
+	// ExecPersist runs the command once, and updates the block with every line
+	// it writes. Clicks are written to it's stdin as JSON
+	ExecPersist ExecMode = "persist"
+)
+
+// sigRTMin is the first real time signal usable by programs on Linux. An
+// Exec's Signal is relative to it, like i3blocks' signal property
+const sigRTMin = 34
+
+// exitUrgent is the exit code which marks the block as urgent
+const exitUrgent = 33
+
+// Exec runs an external command and displays it's output, following the
+// conventions of i3blocks. The command's output is either lines of text, being
+// the full text, short text and color, or a block in the i3bar protocol's
+// format if JSON is set. If the command exits with status 33 the block is
+// urgent.
+//
+// When the block is clicked the command is run again with BLOCK_BUTTON,
+// BLOCK_X, BLOCK_Y, BLOCK_RELATIVE_X, BLOCK_RELATIVE_Y, BLOCK_WIDTH,
+// BLOCK_HEIGHT and BLOCK_MODIFIERS set. BLOCK_NAME, BLOCK_INSTANCE and
+// BLOCK_INTERVAL are always set
+type Exec struct {
+	// Command is run with sh -c
+	Command string
+
+	Mode     ExecMode
+	Interval time.Duration
+
+	// JSON parses the output as an i3bar block rather than lines of text
+	JSON bool
+
+	// Timeout is how long the command may run before it's process group is
+	// killed. If unset 10 seconds is used. It does not apply to ExecPersist
+	Timeout time.Duration
+
+	// Signal, if set, causes the command to run when the process receives
+	// SIGRTMIN+Signal, such as with `pkill -RTMIN+1 my3status`
+	Signal int
+
+	// Name and Instance are set on the block, and passed to the command
+	Name     string
+	Instance string
+
+	// Label is prepended to the command's full text
+	Label string
+
+	// Env is added to the command's environment, as "KEY=value"
+	Env []string
+
+	// Defaults fill in the fields of the command's block which it leaves
+	// unset, like the properties of an i3blocks block. Urgent is set if either
+	// sets it
+	Defaults StatusBlock
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	ran     bool
+	force   bool
+	next    time.Time
+	cmd     *exec.Cmd
+	stdin   *stdinWriter
+	block   StatusBlock
+	err     error
+	update  Updater
+	signals chan os.Signal
+}
+
+func (e *Exec) SetUpdater(u Updater) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.update = u
+}
+
+func (e *Exec) Status() (StatusBlock, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.started {
+		e.started = true
+		e.watchSignal()
+	}
+	if e.cmd == nil && !e.closed && e.due() {
+		e.run(nil)
+	}
+	return e.block, e.err
+}
+
+// due returns true if the command should be run again. e.mu must be held
+func (e *Exec) due() bool {
+	if e.force || !e.ran {
+		return true
+	}
+	switch e.Mode {
+	case ExecRepeat:
+		return true
+	case ExecInterval:
+		return e.Interval > 0 && !time.Now().Before(e.next)
+	}
+	return false
+}
+
+// Click runs the command with the click in it's environment, or writes it to
+// a persistent command's stdin
+func (e *Exec) Click(c ClickEvent) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.Mode == ExecPersist {
+		if e.stdin != nil {
+			data, _ := json.Marshal(c)
+			e.stdin.send(append(data, '\n'))
+		}
+		return false
+	}
+	if e.cmd != nil || e.closed {
+		return false
+	}
+	e.run(&c)
+	return false
+}
+
+// watchSignal runs the command when Signal is received. e.mu must be held
+func (e *Exec) watchSignal() {
+	if e.Signal <= 0 {
+		return
+	}
+	e.signals = make(chan os.Signal, 1)
+	signal.Notify(e.signals, syscall.Signal(sigRTMin+e.Signal))
+	go func(signals chan os.Signal) {
+		for range signals {
+			e.mu.Lock()
+			e.force = true
+			update := e.update
+			e.mu.Unlock()
+			if update != nil {
+				update()
+			}
+		}
+	}(e.signals)
+}
+
+// env returns the BLOCK_ environment variables for the command
+func (e *Exec) env(c *ClickEvent) []string {
+	env := append([]string{}, e.Env...)
+	env = append(env,
+		"BLOCK_NAME="+e.Name,
+		"BLOCK_INSTANCE="+e.Instance,
+		"BLOCK_INTERVAL="+strconv.Itoa(int(e.Interval/time.Second)),
+	)
+	if c != nil {
+		env = append(env,
+			"BLOCK_BUTTON="+strconv.Itoa(c.Button),
+			"BLOCK_X="+strconv.Itoa(c.X),
+			"BLOCK_Y="+strconv.Itoa(c.Y),
+			"BLOCK_RELATIVE_X="+strconv.Itoa(c.RelativeX),
+			"BLOCK_RELATIVE_Y="+strconv.Itoa(c.RelativeY),
+			"BLOCK_WIDTH="+strconv.Itoa(c.Width),
+			"BLOCK_HEIGHT="+strconv.Itoa(c.Height),
+			"BLOCK_MODIFIERS="+strings.Join(c.Modifiers, ","),
+		)
+	}
+	return env
+}
+
+// run starts the command in the background. e.mu must be held
+func (e *Exec) run(c *ClickEvent) {
+	cmd := exec.Command("sh", "-c", e.Command)
+	cmd.Env = append(os.Environ(), e.env(c)...)
+	// the command gets it's own process group, so anything it starts is
+	// killed with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	e.ran = true
+	e.force = false
+	e.next = time.Now().Add(e.Interval)
+
+	if e.Mode == ExecPersist {
+		e.runPersist(cmd)
+		return
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Start()
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.cmd = cmd
+	trackProcessGroup(cmd.Process.Pid)
+
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	var timedOut int32
+	timer := time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		killProcessGroup(cmd.Process.Pid)
+	})
+
+	go func() {
+		err := cmd.Wait()
+		timer.Stop()
+		untrackProcessGroup(cmd.Process.Pid)
+
+		var block StatusBlock
+		if atomic.LoadInt32(&timedOut) != 0 {
+			err = fmt.Errorf("timed out after %v", timeout)
+		} else {
+			block, err = e.parseOutput(stdout.Bytes(), stderr.Bytes(), err)
+		}
+
+		e.mu.Lock()
+		e.cmd = nil
+		e.block, e.err = block, err
+		update := e.update
+		e.mu.Unlock()
+		if update != nil {
+			update()
+		}
+	}()
+}
+
+// runPersist starts a persistent command. e.mu must be held
+func (e *Exec) runPersist(cmd *exec.Cmd) {
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		e.err = err
+		return
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		e.err = err
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.cmd = cmd
+	e.stdin = newStdinWriter(stdin)
+	trackProcessGroup(cmd.Process.Pid)
+
+	go func() {
+		br := bufio.NewReader(stdout)
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				block, perr := e.parseLine(line)
+				e.mu.Lock()
+				e.block, e.err = block, perr
+				update := e.update
+				e.mu.Unlock()
+				if update != nil {
+					update()
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		err := cmd.Wait()
+		untrackProcessGroup(cmd.Process.Pid)
+
+		e.mu.Lock()
+		e.cmd = nil
+		e.stdin.close()
+		e.stdin = nil
+		if err != nil && !e.closed {
+			e.err = err
+		}
+		update := e.update
+		e.mu.Unlock()
+		if update != nil {
+			update()
+		}
+	}()
+}
+
+// parseOutput parses the output of a command which has exited with waitErr
+func (e *Exec) parseOutput(stdout, stderr []byte, waitErr error) (StatusBlock, error) {
+	urgent := false
+	if waitErr != nil {
+		ee, ok := waitErr.(*exec.ExitError)
+		if !ok || ee.ExitCode() != exitUrgent {
+			msg := strings.TrimSpace(string(stderr))
+			if nl := strings.IndexByte(msg, '\n'); nl != -1 {
+				msg = msg[:nl]
+			}
+			if msg != "" {
+				return StatusBlock{}, fmt.Errorf("%v: %v", waitErr, msg)
+			}
+			return StatusBlock{}, waitErr
+		}
+		urgent = true
+	}
+
+	var block StatusBlock
+	if e.JSON {
+		err := json.Unmarshal(bytes.TrimSpace(stdout), &block)
+		if err != nil && len(bytes.TrimSpace(stdout)) > 0 {
+			return StatusBlock{}, fmt.Errorf("invalid output: %v", err)
+		}
+	} else {
+		lines := strings.Split(strings.TrimRight(string(stdout), "\n"), "\n")
+		block.FullText = lines[0]
+		if len(lines) > 1 {
+			block.ShortText = lines[1]
+		}
+		if len(lines) > 2 && lines[2] != "" {
+			c, err := parseColor(lines[2])
+			if err != nil {
+				return StatusBlock{}, err
+			}
+			block.Color = c
+		}
+	}
+	block.Urgent = block.Urgent || urgent
+	e.decorate(&block)
+	return block, nil
+}
+
+// parseLine parses a line written by a persistent command
+func (e *Exec) parseLine(line []byte) (StatusBlock, error) {
+	var block StatusBlock
+	if e.JSON {
+		err := json.Unmarshal(line, &block)
+		if err != nil {
+			return StatusBlock{}, fmt.Errorf("invalid output: %v", err)
+		}
+	} else {
+		block.FullText = strings.TrimRight(string(line), "\r\n")
+	}
+	e.decorate(&block)
+	return block, nil
+}
+
+// decorate applies Defaults, Name, Instance and Label to a block from the
+// command
+func (e *Exec) decorate(block *StatusBlock) {
+	d := &e.Defaults
+	if block.Color == nil {
+		block.Color = d.Color
+	}
+	if block.Background == nil {
+		block.Background = d.Background
+	}
+	if block.Border == nil {
+		block.Border = d.Border
+	}
+	if block.BorderTop == nil {
+		block.BorderTop = d.BorderTop
+	}
+	if block.BorderRight == nil {
+		block.BorderRight = d.BorderRight
+	}
+	if block.BorderBottom == nil {
+		block.BorderBottom = d.BorderBottom
+	}
+	if block.BorderLeft == nil {
+		block.BorderLeft = d.BorderLeft
+	}
+	if block.MinWidth == 0 && block.MinWidthText == "" {
+		block.MinWidth = d.MinWidth
+		block.MinWidthText = d.MinWidthText
+	}
+	if block.Align == "" {
+		block.Align = d.Align
+	}
+	if block.Markup == "" {
+		block.Markup = d.Markup
+	}
+	block.Urgent = block.Urgent || d.Urgent
+	if block.Separator.Hide == nil {
+		block.Separator.Hide = d.Separator.Hide
+	}
+	if block.Separator.Width == nil {
+		block.Separator.Width = d.Separator.Width
+	}
+	if e.Label != "" {
+		block.FullText = e.Label + block.FullText
+	}
+	if e.Name != "" {
+		block.Name = e.Name
+	}
+	if e.Instance != "" {
+		block.Instance = e.Instance
+	}
+}
+
+// Close kills the command, and stops watching for Signal
+func (e *Exec) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	if e.signals != nil {
+		signal.Stop(e.signals)
+		close(e.signals)
+		e.signals = nil
+	}
+	if e.cmd != nil {
+		killProcessGroup(e.cmd.Process.Pid)
+	}
+	return nil
+}
+
+// stdinBuffer is how many lines a stdinWriter holds for a child which is not
+// reading them
+const stdinBuffer = 16
+
+// stdinWriter writes lines to a child's stdin in order, without blocking the
+// sender
+type stdinWriter struct {
+	lines chan []byte
+}
+
+func newStdinWriter(w io.WriteCloser) *stdinWriter {
+	s := &stdinWriter{
+		lines: make(chan []byte, stdinBuffer),
+	}
+	go func() {
+		defer w.Close()
+		for line := range s.lines {
+			// after an error the lines are still drained, so send never
+			// blocks
+			w.Write(line)
+		}
+	}()
+	return s
+}
+
+// send queues line to be written. It is dropped if the buffer is full
+func (s *stdinWriter) send(line []byte) {
+	select {
+	case s.lines <- line:
+	default:
+		fmt.Fprintf(os.Stderr, "child is not reading it's stdin, dropping %q\n", bytes.TrimSpace(line))
+	}
+}
+
+// close closes the stdin once the queued lines have been written
+func (s *stdinWriter) close() {
+	close(s.lines)
+}
+
+var processGroupLock = &sync.Mutex{}
+var processGroups = map[int]bool{}
+var processGroupCleanup = &sync.Once{}
+
+// trackProcessGroup records a running process group, so it can be killed
+// before the process Restarts or exits
+func trackProcessGroup(pgid int) {
+	processGroupCleanup.Do(func() {
+		BeforeRestart(killProcessGroups)
+		BeforeExit(killProcessGroups)
+	})
+	processGroupLock.Lock()
+	defer processGroupLock.Unlock()
+	processGroups[pgid] = true
+}
+
+func untrackProcessGroup(pgid int) {
+	processGroupLock.Lock()
+	defer processGroupLock.Unlock()
+	delete(processGroups, pgid)
+}
+
+func killProcessGroup(pgid int) {
+	unix.Kill(-pgid, unix.SIGKILL)
+}
+
+func killProcessGroups() {
+	processGroupLock.Lock()
+	defer processGroupLock.Unlock()
+	for pgid := range processGroups {
+		killProcessGroup(pgid)
+	}
+}
@@ -0,0 +1,67 @@
+package my3status
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestExecPersistClicks(t *testing.T) {
+	updates := make(chan struct{}, 1)
+	e := &Exec{
+		Mode: ExecPersist,
+		// prints the buttons of every click so far
+		Command: `echo ready; s=; while read -r line; do s="$s$(echo "$line" | sed 's/.*"button":\([0-9]*\).*/\1/')"; echo "$s"; done`,
+	}
+	e.SetUpdater(func() {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	})
+	defer e.Close()
+
+	waitFor := func(fullText string) {
+		timeout := time.After(5 * time.Second)
+		for {
+			b, err := e.Status()
+			if err == nil && b.FullText == fullText {
+				return
+			}
+			select {
+			case <-updates:
+			case <-timeout:
+				t.Fatalf("got %q (%v), expected %q", b.FullText, err, fullText)
+			}
+		}
+	}
+
+	waitFor("ready")
+	for button := 1; button <= 5; button++ {
+		e.Click(ClickEvent{Button: button})
+	}
+	waitFor("12345")
+}
+
+func TestExecDefaults(t *testing.T) {
+	e := &Exec{
+		Defaults: StatusBlock{
+			Color:      color.NRGBA{G: 0xFF, A: 0xFF},
+			Background: color.NRGBA{A: 0xFF},
+			Urgent:     true,
+			Separator: Separator{
+				Hide: BoolPtr(true),
+			},
+		},
+	}
+	block, err := e.parseOutput([]byte("text\n\n#FF0000\n"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Color != (color.NRGBA{R: 0xFF, A: 0xFF}) {
+		t.Errorf("the default color replaced the command's: %v", block.Color)
+	}
+	if block.Background != e.Defaults.Background || !block.Urgent || block.Separator.Hide == nil || !*block.Separator.Hide {
+		t.Errorf("the defaults were not applied: %+v", block)
+	}
+}
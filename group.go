@@ -0,0 +1,102 @@
+package my3status
+
+import "encoding/json"
+
+// Group renders each of it's constituent widgets next to each other. Clicks
+// are forwarded to the Widget that rendered the clicked block
+type Group struct {
+	Widgets []Widget
+
+	// the number of blocks each Widget rendered in the last frame
+	counts []int
+	blocks []StatusBlock
+}
+
+// Status returns the first block of the Group
+func (g *Group) Status() (StatusBlock, error) {
+	sbs, err := g.StatusBlocks()
+	if err != nil || len(sbs) == 0 {
+		return StatusBlock{}, err
+	}
+	return sbs[0], nil
+}
+
+// StatusBlocks returns the blocks of every Widget in the Group. The returned
+// slice is reused by the next call
+func (g *Group) StatusBlocks() ([]StatusBlock, error) {
+	if len(g.counts) != len(g.Widgets) {
+		g.counts = make([]int, len(g.Widgets))
+	}
+	g.blocks = g.blocks[:0]
+	for i, w := range g.Widgets {
+		start := len(g.blocks)
+		var err error
+		g.blocks, err = appendStatusBlocks(g.blocks, w)
+		if err != nil {
+			return nil, err
+		}
+		g.counts[i] = len(g.blocks) - start
+	}
+	return g.blocks, nil
+}
+
+func (g *Group) Click(c ClickEvent) bool {
+	for i, count := range g.counts {
+		if c.Block < count {
+			cw, ok := g.Widgets[i].(ClickableWidget)
+			if !ok {
+				return false
+			}
+			return cw.Click(c)
+		}
+		c.Block -= count
+	}
+	return false
+}
+
+func (g *Group) SetUpdater(u Updater) {
+	for _, w := range g.Widgets {
+		setUpdater(w, u)
+	}
+}
+
+func (g *Group) Pause() {
+	for _, w := range g.Widgets {
+		pause(w)
+	}
+}
+
+func (g *Group) Resume() {
+	for _, w := range g.Widgets {
+		resume(w)
+	}
+}
+
+func (g *Group) Close() error {
+	return closeWidgets(g.Widgets)
+}
+
+func (g *Group) SaveState() ([]byte, error) {
+	states, err := saveStates(g.Widgets)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(states)
+}
+
+func (g *Group) RestoreState(data []byte) error {
+	var states [][]byte
+	err := json.Unmarshal(data, &states)
+	if err != nil {
+		return err
+	}
+	return restoreStates(g.Widgets, states)
+}
+
+func (g *Group) WatchedFiles() []string {
+	var files []string
+	for _, w := range g.Widgets {
+		files = appendWatchedFiles(files, w)
+	}
+	return files
+}
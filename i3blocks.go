@@ -0,0 +1,315 @@
+package my3status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// LoadI3blocksConfig builds a Config from an i3blocks config file, such as
+//
+//	separator_block_width=15
+//	interval=5
+//
+//	[volume]
+//	command=~/.config/i3blocks/volume
+//	instance=Master
+//	signal=10
+//
+//	[time]
+//	command=date '+%H:%M'
+//	interval=1
+//
+// Blocks with a command become Exec widgets, and blocks with only a full_text
+// become text widgets. As in i3blocks, properties such as color and min_width
+// are defaults, which the command's output takes precedence over. Properties
+// before the first block apply to every block, except separator and
+// separator_block_width, which set the DefaultSeparator. Properties which are
+// not understood are passed to the command as environment variables, as
+// i3blocks does.
+//
+// Errors are returned as a *ConfigError. The returned Config's ConfigFile is
+// set to path, so Loop reloads it when it changes
+func LoadI3blocksConfig(path string) (Config, error) {
+	return loadI3blocksConfig(path, func(int, *WidgetConfig) Widget {
+		return nil
+	})
+}
+
+// ConvertI3blocksConfig converts an i3blocks config file into a JSON config
+// file for LoadConfig, which native widgets can then be added to
+func ConvertI3blocksConfig(path string) ([]byte, error) {
+	fc, _, err := readI3blocksConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	out := struct {
+		Separator      *bool             `json:"separator,omitempty"`
+		SeparatorWidth *int              `json:"separator_block_width,omitempty"`
+		Widgets        []json.RawMessage `json:"widgets"`
+	}{
+		Separator:      fc.Separator,
+		SeparatorWidth: fc.SeparatorWidth,
+		Widgets:        []json.RawMessage{},
+	}
+	for _, wc := range fc.Widgets {
+		out.Widgets = append(out.Widgets, json.RawMessage(wc.raw))
+	}
+	data, err := json.MarshalIndent(out, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// loadI3blocksConfig is LoadI3blocksConfig, but reuses widgets like loadConfig
+func loadI3blocksConfig(path string, reuse func(index int, wc *WidgetConfig) Widget) (Config, error) {
+	fc, lines, err := readI3blocksConfig(path)
+	if err != nil {
+		return Config{}, err
+	}
+	c, err := fc.build(path, reuse, func(index int, err error) error {
+		if index < 0 {
+			return &ConfigError{
+				File: path,
+				Err:  err,
+			}
+		}
+		return &ConfigError{
+			File:   path,
+			Line:   lines[index],
+			Column: 1,
+			Err:    err,
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	c.i3blocks = true
+	return c, nil
+}
+
+// readI3blocksConfig converts an i3blocks config file into the form of a JSON
+// config file. The line each widget's block starts on is also returned
+func readI3blocksConfig(path string) (*fileConfig, []int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	global, blocks, err := parseI3blocks(path, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fc := &fileConfig{}
+	if p, ok := global.props["separator"]; ok {
+		v, err := strconv.ParseBool(p.value)
+		if err != nil {
+			return nil, nil, p.errorf(path, "invalid separator %q", p.value)
+		}
+		fc.Separator = &v
+	}
+	if p, ok := global.props["separator_block_width"]; ok {
+		v, err := strconv.Atoi(p.value)
+		if err != nil {
+			return nil, nil, p.errorf(path, "invalid separator_block_width %q", p.value)
+		}
+		fc.SeparatorWidth = &v
+	}
+
+	lines := make([]int, len(blocks))
+	for i, block := range blocks {
+		obj, err := block.widget(path, global)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		wc := &WidgetConfig{}
+		err = json.Unmarshal(data, wc)
+		if err != nil {
+			return nil, nil, err
+		}
+		fc.Widgets = append(fc.Widgets, wc)
+		lines[i] = block.line
+	}
+	return fc, lines, nil
+}
+
+// i3blocksProperty is a key=value line of an i3blocks config
+type i3blocksProperty struct {
+	value string
+	line  int
+}
+
+func (p i3blocksProperty) errorf(file, format string, args ...interface{}) error {
+	return &ConfigError{
+		File:   file,
+		Line:   p.line,
+		Column: 1,
+		Err:    fmt.Errorf(format, args...),
+	}
+}
+
+// i3blocksBlock is a [name] section of an i3blocks config, or the global
+// properties before the first one
+type i3blocksBlock struct {
+	name  string
+	line  int
+	props map[string]i3blocksProperty
+}
+
+// parseI3blocks splits an i3blocks config into it's global properties and it's
+// blocks
+func parseI3blocks(file string, data []byte) (i3blocksBlock, []i3blocksBlock, error) {
+	global := i3blocksBlock{
+		props: map[string]i3blocksProperty{},
+	}
+	blocks := []i3blocksBlock{}
+	current := &global
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		lineErr := func(format string, args ...interface{}) error {
+			return i3blocksProperty{line: i + 1}.errorf(file, format, args...)
+		}
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return global, nil, lineErr("expected ] after block name")
+			}
+			blocks = append(blocks, i3blocksBlock{
+				name:  strings.TrimSpace(line[1 : len(line)-1]),
+				line:  i + 1,
+				props: map[string]i3blocksProperty{},
+			})
+			current = &blocks[len(blocks)-1]
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return global, nil, lineErr("expected key=value")
+		}
+		current.props[strings.TrimSpace(line[:eq])] = i3blocksProperty{
+			value: strings.TrimSpace(line[eq+1:]),
+			line:  i + 1,
+		}
+	}
+	return global, blocks, nil
+}
+
+// widget returns the JSON object of the widget equivalent to b
+func (b i3blocksBlock) widget(file string, global i3blocksBlock) (map[string]interface{}, error) {
+	props := map[string]i3blocksProperty{}
+	for key, p := range global.props {
+		// these are set on the DefaultSeparator instead
+		if key != "separator" && key != "separator_block_width" {
+			props[key] = p
+		}
+	}
+	for key, p := range b.props {
+		props[key] = p
+	}
+
+	obj := map[string]interface{}{
+		"type": "exec",
+	}
+	if _, ok := props["command"]; !ok {
+		obj["type"] = "text"
+	}
+	if b.name != "" {
+		obj["name"] = b.name
+	}
+	env := map[string]string{}
+	// the command's output takes precedence over these, so they are defaults
+	// rather than overrides
+	defaults := obj
+	if obj["type"] == "exec" {
+		defaults = map[string]interface{}{}
+	}
+	for key, p := range props {
+		switch key {
+		case "command", "instance", "name", "label":
+			obj[key] = p.value
+		case "align", "markup":
+			defaults[key] = p.value
+		case "full_text", "short_text":
+			// commands replace these as soon as they run
+			if obj["type"] == "text" {
+				obj[key] = p.value
+			}
+		case "color", "background", "border":
+			_, err := parseColor(p.value)
+			if err != nil {
+				return nil, p.errorf(file, "%v: %v", key, err)
+			}
+			defaults[key] = p.value
+		case "border_top", "border_right", "border_bottom", "border_left", "separator_block_width":
+			v, err := strconv.Atoi(p.value)
+			if err != nil {
+				return nil, p.errorf(file, "invalid %v %q", key, p.value)
+			}
+			defaults[key] = v
+		case "signal":
+			v, err := strconv.Atoi(p.value)
+			if err != nil {
+				return nil, p.errorf(file, "invalid %v %q", key, p.value)
+			}
+			obj[key] = v
+		case "min_width":
+			if v, err := strconv.Atoi(p.value); err == nil {
+				defaults[key] = v
+			} else {
+				defaults[key] = p.value
+			}
+		case "separator", "urgent":
+			v, err := strconv.ParseBool(p.value)
+			if err != nil {
+				return nil, p.errorf(file, "invalid %v %q", key, p.value)
+			}
+			defaults[key] = v
+		case "interval":
+			switch ExecMode(p.value) {
+			case ExecOnce, ExecRepeat, ExecPersist:
+				obj[key] = p.value
+			default:
+				v, err := strconv.ParseFloat(p.value, 64)
+				if err != nil || v < 0 {
+					return nil, p.errorf(file, "invalid interval %q", p.value)
+				}
+				obj[key] = v
+			}
+		case "format":
+			if p.value != "json" {
+				return nil, p.errorf(file, "unknown format %q", p.value)
+			}
+			obj[key] = p.value
+		default:
+			env[key] = p.value
+		}
+	}
+
+	if obj["type"] == "text" {
+		// text widgets do not run anything, so only the label matters
+		if label, ok := obj["label"].(string); ok {
+			text, _ := obj["full_text"].(string)
+			obj["full_text"] = label + text
+		}
+		for _, key := range []string{"label", "interval", "signal", "format"} {
+			delete(obj, key)
+		}
+	} else {
+		if len(env) > 0 {
+			obj["env"] = env
+		}
+		if len(defaults) > 0 {
+			obj["defaults"] = defaults
+		}
+	}
+	return obj, nil
+}
@@ -0,0 +1,191 @@
+package my3status
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseI3blocks(t *testing.T) {
+	global, blocks, err := parseI3blocks("i3blocks.conf", []byte(`# global properties
+separator_block_width = 15
+interval=5
+
+[volume]
+command=amixer get Master | grep -o '[0-9]*%'
+ instance = Master
+
+# comments and blank lines are skipped
+[ time ]
+command=date '+%H:%M'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedGlobal := map[string]i3blocksProperty{
+		"separator_block_width": {"15", 2},
+		"interval":              {"5", 3},
+	}
+	if !reflect.DeepEqual(global.props, expectedGlobal) {
+		t.Errorf("global properties are %v, expected %v", global.props, expectedGlobal)
+	}
+	expected := []i3blocksBlock{
+		{"volume", 5, map[string]i3blocksProperty{
+			"command":  {"amixer get Master | grep -o '[0-9]*%'", 6},
+			"instance": {"Master", 7},
+		}},
+		{"time", 10, map[string]i3blocksProperty{
+			"command": {"date '+%H:%M'", 11},
+		}},
+	}
+	if !reflect.DeepEqual(blocks, expected) {
+		t.Errorf("blocks are %v, expected %v", blocks, expected)
+	}
+
+	errors := []struct {
+		config string
+		line   int
+	}{
+		{"[volume\ncommand=true", 1},
+		{"[volume]\ncommand=true\n=5", 3},
+		{"interval=5\nnot a property", 2},
+	}
+	for _, test := range errors {
+		_, _, err := parseI3blocks("i3blocks.conf", []byte(test.config))
+		cerr, ok := err.(*ConfigError)
+		if !ok || cerr.Line != test.line {
+			t.Errorf("%q: expected an error on line %v, got %v", test.config, test.line, err)
+		}
+	}
+}
+
+func writeI3blocksConfig(t *testing.T, config string) (string, func()) {
+	dir, err := ioutil.TempDir("", "my3status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "i3blocks.conf")
+	err = ioutil.WriteFile(path, []byte(config), 0644)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestConvertI3blocksConfig(t *testing.T) {
+	path, cleanup := writeI3blocksConfig(t, `separator_block_width=15
+color=#00FF00
+align=center
+
+[disk]
+command=df -h / | tail -1
+instance=/
+label=DISK
+interval=30
+min_width=100
+markup=pango
+border_top=2
+separator=false
+urgent=true
+signal=3
+THRESHOLD=90
+
+[plain]
+full_text=hello
+label=>
+`)
+	defer cleanup()
+	data, err := ConvertI3blocksConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := struct {
+		SeparatorWidth int                      `json:"separator_block_width"`
+		Widgets        []map[string]interface{} `json:"widgets"`
+	}{}
+	err = json.Unmarshal(data, &converted)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	if converted.SeparatorWidth != 15 {
+		t.Errorf("separator_block_width is %v, expected 15", converted.SeparatorWidth)
+	}
+
+	expected := []map[string]interface{}{
+		{
+			"type":     "exec",
+			"name":     "disk",
+			"command":  "df -h / | tail -1",
+			"instance": "/",
+			"label":    "DISK",
+			"interval": 30.0,
+			"signal":   3.0,
+			"env":      map[string]interface{}{"THRESHOLD": "90"},
+			// the command's output takes precedence over these
+			"defaults": map[string]interface{}{
+				"color":      "#00FF00",
+				"align":      "center",
+				"min_width":  100.0,
+				"markup":     "pango",
+				"border_top": 2.0,
+				"separator":  false,
+				"urgent":     true,
+			},
+		},
+		{
+			"type":      "text",
+			"name":      "plain",
+			"full_text": ">hello",
+			"color":     "#00FF00",
+			"align":     "center",
+		},
+	}
+	if !reflect.DeepEqual(converted.Widgets, expected) {
+		t.Errorf("converted to\n%s", data)
+	}
+}
+
+func TestI3blocksJSONOutput(t *testing.T) {
+	// the command's block is kept as is, but fills in the block's defaults
+	path, cleanup := writeI3blocksConfig(t, `min_width=100
+align=center
+markup=pango
+color=#00FF00
+
+[json]
+command=echo '{"full_text":"out","min_width":5,"align":"left","markup":"none"}'
+format=json
+`)
+	defer cleanup()
+	c, err := LoadI3blocksConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeWidgets(c.Widgets)
+
+	deadline := time.Now().Add(5 * time.Second)
+	var block StatusBlock
+	for block.FullText == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("the command did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+		block, err = c.Widgets[0].Status()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if block.MinWidth != 5 || block.Align != AlignLeft || block.Markup != MarkupNone {
+		t.Errorf("the config replaced the command's block: %+v", block)
+	}
+	if block.Color == nil {
+		t.Error("the default color was not applied")
+	}
+}
@@ -0,0 +1,179 @@
+package my3status
+
+import (
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Lemonbar renders blocks in lemonbar's format. Clicks are reported by
+// lemonbar on it's stdout, which must be piped back into my3status, for
+// example with
+//
+//	mkfifo /tmp/my3status
+//	my3status < /tmp/my3status | lemonbar > /tmp/my3status
+type Lemonbar struct {
+	// Prefix is written at the start of every line. If unset "%{r}" is used,
+	// which aligns the blocks to the right of the bar
+	Prefix string
+
+	// Separator is drawn between blocks which do not hide their separator. If
+	// unset "|" is used
+	Separator string
+
+	// The colors used for urgent blocks. If both are unset the block's colors
+	// are reversed instead
+	UrgentColor      color.Color
+	UrgentBackground color.Color
+}
+
+// lemonbarButtons are the buttons which are bound to a click action
+var lemonbarButtons = []int{ButtonLeft, ButtonMiddle, ButtonRight, ButtonScrollUp, ButtonScrollDown}
+
+func (l Lemonbar) AppendHeader(dst []byte, c Config) []byte {
+	return dst
+}
+
+func (l Lemonbar) AppendFrame(dst []byte, blocks []StatusBlock) []byte {
+	prefix := l.Prefix
+	if prefix == "" {
+		prefix = "%{r}"
+	}
+	separator := l.Separator
+	if separator == "" {
+		separator = "|"
+	}
+
+	dst = append(dst, prefix...)
+	var prev *StatusBlock
+	for i, s := range blocks {
+		if s.FullText == "" {
+			continue
+		}
+		if prev != nil {
+			dst = l.appendSeparator(dst, separator, *prev)
+		}
+		prev = &blocks[i]
+		dst = l.appendBlock(dst, s)
+	}
+	return append(dst, '\n')
+}
+
+func (l Lemonbar) appendBlock(dst []byte, s StatusBlock) []byte {
+	for _, button := range lemonbarButtons {
+		dst = append(dst, "%{A"...)
+		dst = strconv.AppendInt(dst, int64(button), 10)
+		dst = append(dst, ':')
+		dst = append(dst, clickAction(button, s)...)
+		dst = append(dst, ":}"...)
+	}
+
+	fg, bg := s.Color, s.Background
+	reverse := false
+	if s.Urgent {
+		if l.UrgentColor == nil && l.UrgentBackground == nil {
+			reverse = true
+		} else {
+			fg, bg = l.UrgentColor, l.UrgentBackground
+		}
+	}
+	if reverse {
+		dst = append(dst, "%{R}"...)
+	}
+	dst = appendLemonbarColor(dst, 'F', fg)
+	dst = appendLemonbarColor(dst, 'B', bg)
+
+	if s.Markup == MarkupPango {
+		for _, span := range parsePango(s.FullText) {
+			if span.Foreground != nil {
+				dst = appendLemonbarColor(dst, 'F', span.Foreground)
+			}
+			if span.Background != nil {
+				dst = appendLemonbarColor(dst, 'B', span.Background)
+			}
+			if span.Underline {
+				if span.UnderlineColor != nil {
+					dst = appendLemonbarColor(dst, 'U', span.UnderlineColor)
+				}
+				dst = append(dst, "%{+u}"...)
+			}
+			dst = appendLemonbarText(dst, span.Text)
+			if span.Underline {
+				dst = append(dst, "%{-u}%{U-}"...)
+			}
+			if span.Foreground != nil {
+				dst = appendLemonbarColor(dst, 'F', fg)
+			}
+			if span.Background != nil {
+				dst = appendLemonbarColor(dst, 'B', bg)
+			}
+		}
+	} else {
+		dst = appendLemonbarText(dst, s.FullText)
+	}
+
+	dst = append(dst, "%{F-}%{B-}"...)
+	if reverse {
+		dst = append(dst, "%{R}"...)
+	}
+	for range lemonbarButtons {
+		dst = append(dst, "%{A}"...)
+	}
+	return dst
+}
+
+// appendSeparator appends the gap after s
+func (l Lemonbar) appendSeparator(dst []byte, separator string, s StatusBlock) []byte {
+	left, right, draw := separatorGap(s)
+	if !draw {
+		return appendLemonbarOffset(dst, left+right)
+	}
+	dst = appendLemonbarOffset(dst, left)
+	dst = appendLemonbarText(dst, separator)
+	return appendLemonbarOffset(dst, right)
+}
+
+func appendLemonbarOffset(dst []byte, pixels int) []byte {
+	dst = append(dst, "%{O"...)
+	dst = strconv.AppendInt(dst, int64(pixels), 10)
+	return append(dst, '}')
+}
+
+// appendLemonbarColor appends a color command, such as %{F#RRGGBB}, or resets
+// the color if c is nil
+func appendLemonbarColor(dst []byte, command byte, c color.Color) []byte {
+	dst = append(dst, '%', '{', command)
+	if c == nil {
+		dst = append(dst, '-')
+	} else {
+		dst = appendARGBColor(dst, c)
+	}
+	return append(dst, '}')
+}
+
+// appendARGBColor appends c in the form #RRGGBB or #AARRGGBB
+func appendARGBColor(dst []byte, c color.Color) []byte {
+	start := len(dst)
+	dst = appendHexColor(dst, c)
+	if len(dst)-start == len("#RRGGBBAA") {
+		rgb := string(dst[start+1 : start+7])
+		copy(dst[start+1:], dst[start+7:start+9])
+		copy(dst[start+3:], rgb)
+	}
+	return dst
+}
+
+func appendLemonbarText(dst []byte, text string) []byte {
+	text = strings.Replace(text, "\n", " ", -1)
+	return append(dst, strings.Replace(text, "%", "%%", -1)...)
+}
+
+func (l Lemonbar) AppendFooter(dst []byte) []byte {
+	return dst
+}
+
+// ReadClicks reads the click actions that lemonbar writes to it's stdout
+func (l Lemonbar) ReadClicks(in io.Reader, fn func(ClickEvent)) error {
+	return readClickActions(in, fn)
+}
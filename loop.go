@@ -2,13 +2,18 @@ package my3status
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"image/color"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -22,182 +27,930 @@ type Config struct {
 
 	// If false, watch the binary for changes and restart if it is changed. This
 	// restarts the binary in place, and can leak fds opened by other packages.
+	// Files are watched with inotify, and a burst of writes, such as from a
+	// build, only causes a single restart
 	DontWatchBinary bool
 
 	// How often to update the bar. If unset 1 second is used
 	Interval time.Duration
+
+	// How long to wait for each Widget's Status before giving up on it for the
+	// current frame. Widgets are collected concurrently; a Widget that misses
+	// its deadline is shown with its last StatusBlock, colored with
+	// StaleColor. If unset half of the Interval is used
+	Timeout time.Duration
+
+	// The Color used for stale blocks. If unset a dim grey is used
+	StaleColor color.Color
+
+	// The minimum time between redraws requested by UpdatingWidgets. Requests
+	// made sooner than this are delayed and coalesced. If unset 100
+	// milliseconds is used
+	MinUpdateInterval time.Duration
+
+	// StopSignal and ContSignal are the signals i3bar sends when the bar is
+	// hidden and shown again. If StopSignal is unset i3bar uses SIGSTOP, which
+	// freezes the process outright. Set it to a catchable signal, such as
+	// SIGUSR2, to have PausableWidgets stop polling while the bar is hidden.
+	// If ContSignal is unset SIGCONT is used
+	StopSignal syscall.Signal
+	ContSignal syscall.Signal
+
+	// Frames identical to the previous one are not written. If KeepAlive is
+	// set a frame is written at least this often regardless, so i3bar can tell
+	// the process is still alive
+	KeepAlive time.Duration
+
+	// Renderer controls the output format. If unset the i3bar protocol is used
+	Renderer Renderer
+
+	// Once causes Run to return after the first frame is written. Widgets
+	// which need a history, such as CPU with a ShortInterval, have none
+	Once bool
+
+	// Supervise causes Loop to Restart the process if it fails, rather than
+	// exiting. Consecutive failures wait exponentially longer before
+	// restarting, up to a minute
+	Supervise bool
+
+	// ControlSocket is the path of a unix socket to listen for commands on,
+	// such as from my3statusctl. If it is "default" DefaultControlSocket is
+	// used. If the socket cannot be listened on, such as when another bar is
+	// using it, the bar runs without it. See ControlRequest for the protocol
+	ControlSocket string
+
+	// ConfigFile is the file the Config was loaded from by LoadConfig or
+	// LoadI3blocksConfig. If set the file is watched, and the Widgets are
+	// rebuilt in place when it changes. Widgets whose configuration did not
+	// change are kept, so they keep their state. Removed Widgets are closed if
+	// they are io.Closers
+	ConfigFile string
+
+	// widgetConfigs are the configurations of Widgets, if they were loaded
+	// from ConfigFile
+	widgetConfigs []*WidgetConfig
+
+	// i3blocks is set if ConfigFile is an i3blocks config
+	i3blocks bool
+}
+
+// withDefaults returns c with unset fields set to their defaults
+func (c Config) withDefaults() Config {
+	if c.Renderer == nil {
+		c.Renderer = I3Bar{}
+	}
+	if c.Interval == 0 {
+		c.Interval = time.Second
+	}
+	if c.Timeout == 0 {
+		c.Timeout = c.Interval / 2
+	}
+	if c.MinUpdateInterval == 0 {
+		c.MinUpdateInterval = 100 * time.Millisecond
+	}
+	if c.StaleColor == nil {
+		c.StaleColor = color.Gray{Y: 0x7F}
+	}
+	if c.ContSignal == 0 {
+		c.ContSignal = syscall.SIGCONT
+	}
+	return c
 }
 
 const (
-	envContinue  = "MY3STATUS_CONTINUE"
-	envSeenToken = "MY3STATUS_SEEN_TOKEN"
-	envValueYes  = "YES"
+	envContinue = "MY3STATUS_CONTINUE"
+	envValueYes = "YES"
 )
 
+// Loop runs the status bar on stdin and stdout, exiting the process when i3bar
+// goes away or an error occurs.
+//
+// Loop handles SIGHUP by Restarting and SIGUSR1 by redrawing immediately. On
+// SIGTERM or SIGINT the Widgets are closed, the output is terminated, and the
+// callbacks added with BeforeExit are called before Loop returns
 func (c Config) Loop() {
-	cont, _ := os.LookupEnv(envContinue)
-	isContinue := cont == envValueYes
-	if !isContinue {
-		_, err := os.Stdout.WriteString(`{"version": 1, "click_events": true}[`)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exit := make(chan os.Signal, 1)
+	signal.Notify(exit, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-exit
+		cancel()
+	}()
+
+	started := time.Now()
+	err := c.runRecovered(ctx, os.Stdin, os.Stdout, readHandoff())
+	if err != nil && c.Supervise && ctx.Err() == nil {
+		superviseRestart(err, started)
+	}
+	runCallbacks(&exitCallbacks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "my3status: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Run runs the status bar, writing frames from the Renderer to out and reading
+// click events from in. It returns nil once in reaches EOF, or once ctx is
+// cancelled, in which case the Renderer's footer is written first. Widgets
+// which are io.Closers are closed before it returns, whatever the reason.
+func (c Config) Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	return c.run(ctx, in, out, nil)
+}
+
+// run runs the bar. If h is set the bar was started by Loop, and it's state is
+// carried across Restarts
+func (c Config) run(ctx context.Context, in io.Reader, w io.Writer, h *handoff) error {
+	header := c
+	c = c.withDefaults()
+	renderer := c.Renderer
+	if h == nil || !h.continued {
+		_, err := w.Write(renderer.AppendHeader(nil, header))
 		if err != nil {
-			panic(fmt.Errorf("unable to write header: %v", err))
+			return fmt.Errorf("unable to write header: %v", err)
 		}
-		os.Setenv(envContinue, envValueYes)
 	}
 
-	var mtime time.Time
 	var binary string
 	if !c.DontWatchBinary {
 		var err error
 		binary, err = os.Executable()
 		if err != nil {
-			panic(fmt.Errorf("unable to get executable: %v", err))
+			return fmt.Errorf("unable to get executable: %v", err)
 		}
-		stat, err := os.Stat(binary)
+	}
+
+	var configPath string
+	var configErr error
+	if c.ConfigFile != "" {
+		var err error
+		configPath, err = filepath.Abs(c.ConfigFile)
 		if err != nil {
-			panic(fmt.Errorf("unable to stat executable: %v", err))
+			return fmt.Errorf("unable to find config: %v", err)
 		}
-		mtime = stat.ModTime()
 	}
 
-	out := make([]map[string]interface{}, 0, len(c.Widgets))
-	enc := json.NewEncoder(os.Stdout)
+	update := make(chan struct{}, 1)
+	updater := Updater(func() {
+		select {
+		case update <- struct{}{}:
+		default:
+		}
+	})
 
-	click := make(chan struct{})
+	keys := c.widgetKeys()
+	states := make([]*widgetState, len(c.Widgets))
+	for i, w := range c.Widgets {
+		key := ""
+		if keys != nil {
+			key = keys[i]
+		}
+		states[i] = newWidgetState(w, key, updater)
+	}
+	if h != nil {
+		h.restore(states)
+	}
+	col := newCollector(states)
+	defer col.close()
+
+	// shutdown closes the Widgets, so they release their connections and child
+	// processes. It runs however run returns, and before a Restart
+	closed := false
+	shutdown := func() {
+		if closed {
+			return
+		}
+		closed = true
+		waitIdle(states, c.Timeout)
+		closeAll(states)
+	}
+	defer shutdown()
+
+	blocks := make([]StatusBlock, 0, len(c.Widgets))
+	var frame, lastFrame []byte
+	var lastWrite time.Time
+
+	routes := &clickRoutes{}
+
+	clicks := make(chan ClickEvent)
+	readErr := make(chan error, 1)
 	go func() {
-		in := io.Reader(os.Stdin)
-		hasTokenStr, _ := os.LookupEnv(envSeenToken)
-		hasToken := hasTokenStr == envValueYes
-		if hasToken {
-			in = io.MultiReader(bytes.NewBufferString(`[{}`), in)
-		}
-		dec := json.NewDecoder(in)
-		t, err := dec.Token()
+		readErr <- renderer.ReadClicks(in, func(ev ClickEvent) {
+			select {
+			case clicks <- ev:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	contSignal := c.ContSignal
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, contSignal)
+	if c.StopSignal != 0 && c.StopSignal != syscall.SIGSTOP {
+		signal.Notify(signals, c.StopSignal)
+	}
+	if h != nil {
+		signal.Notify(signals, syscall.SIGHUP, syscall.SIGUSR1)
+	}
+	defer signal.Stop(signals)
+	paused := false
+
+	// restart re-execs the binary once the Widgets have finished their calls to
+	// Status, saving their state if the bar was started by Loop
+	restart := func() {
+		os.Stderr.WriteString("restarting\n")
+		waitIdle(states, c.Timeout)
+		if h != nil {
+			err := h.save(states, c.Timeout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "unable to save state: %v\n", err)
+			}
+		}
+		shutdown()
+		Restart()
+	}
+
+	ticker := time.NewTicker(c.Interval)
+	defer func() {
+		ticker.Stop()
+	}()
+
+	// changes stays nil if inotify is unavailable, in which case nothing is
+	// watched
+	var changes chan map[string]bool
+	var fileWatcher *watcher
+	if !c.Once {
+		var err error
+		fileWatcher, err = newWatcher()
 		if err != nil {
-			panic(fmt.Errorf("unable to read click header: %v", err))
+			fmt.Fprintf(os.Stderr, "unable to watch files: %v\n", err)
+		} else {
+			defer fileWatcher.Close()
+			changes = fileWatcher.Changes
 		}
-		if t != json.Delim('[') {
-			panic(fmt.Errorf("got unexpected token waiting for header: %v", t))
+	}
+	watch := func(files ...string) {
+		if fileWatcher == nil {
+			return
 		}
-		os.Setenv(envSeenToken, envValueYes)
+		err := fileWatcher.Watch(files...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to watch files: %v\n", err)
+		}
+	}
+	watchWidgets := func() {
+		var files []string
+		for _, ws := range states {
+			files = appendWatchedFiles(files, ws.widget)
+		}
+		watch(files...)
+	}
+	if binary != "" {
+		watch(binary)
+	}
+	if configPath != "" {
+		watch(configPath)
+	}
+	watchWidgets()
 
-		for {
-			data := struct {
-				ClickEvent
-				Name     string `json:"name"`
-				Instance string `json:"instance"`
-			}{}
-			err := dec.Decode(&data)
-			if err != nil {
-				panic(fmt.Errorf("unable to read click event: %v", err))
+	reloadConfig := func() {
+		interval := c.Interval
+		states, configErr = c.reload(states, updater, paused)
+		col.states = states
+		watchWidgets()
+		if c.Interval != interval {
+			ticker.Stop()
+			ticker = time.NewTicker(c.Interval)
+		}
+	}
+
+	// click delivers ev to the Widget which rendered the clicked block,
+	// returning false if there is no such Widget
+	click := func(ev ClickEvent) bool {
+		index, block, ok := routes.lookup(ev.Name, ev.Instance)
+		if !ok || index >= len(states) {
+			return false
+		}
+		ws := states[index]
+		cw, ok := ws.widget.(ClickableWidget)
+		if !ok {
+			return false
+		}
+		ev.Block = block
+		ws.do(func() {
+			if cw.Click(ev) {
+				updater()
 			}
-			index, err := strconv.Atoi(data.Instance)
-			if err != nil || index < 1 || index > len(c.Widgets) {
-				continue
+		})
+		return true
+	}
+
+	controls := make(chan *controlRequest)
+	if c.ControlSocket != "" && !c.Once {
+		done := make(chan struct{})
+		defer close(done)
+		l, err := listenControl(c.ControlSocket, controls, done)
+		if err != nil {
+			// another bar may be using the socket, so this one runs without it
+			fmt.Fprintf(os.Stderr, "unable to listen on control socket: %v\n", err)
+		} else {
+			defer l.Close()
+		}
+	}
+
+	// control handles a command from the control socket, returning true if
+	// the bar should be redrawn
+	control := func(req *controlRequest) bool {
+		switch req.Command {
+		case ControlList:
+			resp := ControlResponse{
+				Blocks: make([]json.RawMessage, len(blocks)),
 			}
-			widget := c.Widgets[index-1]
-			cw, ok := widget.(ClickableWidget)
-			if !ok {
-				continue
+			for i, s := range blocks {
+				resp.Blocks[i] = appendBlock(nil, s)
+			}
+			req.respond(resp)
+		case ControlRefresh:
+			req.respond(ControlResponse{})
+			return true
+		case ControlClick:
+			ev := ClickEvent{
+				Name:     req.Name,
+				Instance: req.Instance,
+				Button:   req.Button,
 			}
-			redraw := cw.Click(data.ClickEvent)
-			if redraw {
-				click <- struct{}{}
+			if ev.Button == 0 {
+				ev.Button = ButtonLeft
+			}
+			if ev.Instance == "" {
+				for _, s := range blocks {
+					if s.Name == ev.Name {
+						ev.Instance = s.Instance
+						break
+					}
+				}
+			}
+			if !click(ev) {
+				req.respond(ControlResponse{
+					Error: fmt.Sprintf("no clickable block named %q %q", ev.Name, ev.Instance),
+				})
+				break
+			}
+			req.respond(ControlResponse{})
+		case ControlPost:
+			name := req.Name
+			if name == "" {
+				name = "message"
+			}
+			if req.Message == nil {
+				req.respond(ControlResponse{Error: "missing message"})
+				break
+			}
+			err := PostMessage(name, *req.Message)
+			if err != nil {
+				req.respond(ControlResponse{Error: err.Error()})
+				break
 			}
+			req.respond(ControlResponse{})
+		case ControlRestart:
+			req.respond(ControlResponse{})
+			restart()
+		default:
+			req.respond(ControlResponse{
+				Error: fmt.Sprintf("unknown command %q", req.Command),
+			})
 		}
-	}()
-
-	interval := c.Interval
-	if interval == 0 {
-		interval = time.Second
+		return false
 	}
-	tick := time.NewTicker(interval).C
+
 	for {
-		if binary != "" {
-			stat, err := os.Stat(binary)
-			if err == nil {
-				if stat.ModTime() != mtime {
-					os.Stderr.WriteString("restarting\n")
-					Restart()
-				}
-			}
+		// anything requested before now will be picked up by this frame
+		select {
+		case <-update:
+		default:
 		}
+		frameStart := time.Now()
+
+		col.collect(c.Timeout)
 
-		for index, seg := range c.Widgets {
-			s, err := seg.Status()
+		routes.reset()
+		blocks = blocks[:0]
+		if configErr != nil {
+			block := errorBlock(configErr)
+			block.Name = "my3status"
+			block.Instance = "config"
+			block.Separator = c.DefaultSeparator
+			blocks = append(blocks, block)
+		}
+		blocks = c.appendBlocks(blocks, states, routes)
 
-			value := make(map[string]interface{}, 16)
+		frame = renderer.AppendFrame(frame[:0], blocks)
 
+		keepAlive := c.KeepAlive != 0 && time.Since(lastWrite) >= c.KeepAlive
+		if keepAlive || !bytes.Equal(frame, lastFrame) {
+			_, err := w.Write(frame)
 			if err != nil {
-				s = StatusBlock{
-					FullText: fmt.Sprintf("error: %v", err),
-					Urgent:   true,
-				}
+				return fmt.Errorf("unable to write output: %v", err)
 			}
+			lastWrite = time.Now()
+			lastFrame = append(lastFrame[:0], frame...)
+		}
+		if c.Once {
+			return nil
+		}
 
-			for k, v := range s.Extra {
-				value[k] = v
+		// throttle fires once an update requested too soon after the last
+		// frame may be drawn
+		var throttle <-chan time.Time
+		redraw := false
+		for !redraw {
+			select {
+			case <-ctx.Done():
+				shutdown()
+				_, err := w.Write(renderer.AppendFooter(frame[:0]))
+				if err != nil {
+					return fmt.Errorf("unable to write output: %v", err)
+				}
+				return nil
+			case err := <-readErr:
+				if err == nil {
+					// the Renderer does not read clicks
+					readErr = nil
+					break
+				}
+				if err == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("unable to read click event: %v", err)
+			case <-ticker.C:
+				redraw = !paused
+			case changed := <-changes:
+				if changed[binary] {
+					// the binary is missing while some tools replace it. It's
+					// directory is watched, so the bar restarts once it is
+					// back in place
+					if executable(binary) {
+						restart()
+					} else {
+						fmt.Fprintf(os.Stderr, "%v changed, but is not an executable file; not restarting\n", binary)
+					}
+				}
+				if changed[configPath] {
+					reloadConfig()
+				}
+				redraw = !paused
+			case ev := <-clicks:
+				click(ev)
+			case req := <-controls:
+				redraw = control(req) && !paused
+			case <-update:
+				if paused {
+					break
+				}
+				if wait := c.MinUpdateInterval - time.Since(frameStart); wait > 0 {
+					if throttle == nil {
+						throttle = time.After(wait)
+					}
+					break
+				}
+				redraw = true
+			case <-throttle:
+				throttle = nil
+				redraw = !paused
+			case sig := <-signals:
+				switch {
+				case sig == contSignal:
+					paused = false
+					for _, ws := range states {
+						ws.do(ws.resume)
+					}
+					redraw = true
+				case sig == c.StopSignal:
+					if !paused {
+						paused = true
+						for _, ws := range states {
+							ws.do(ws.pause)
+						}
+					}
+				case sig == syscall.SIGHUP:
+					restart()
+				case sig == syscall.SIGUSR1:
+					redraw = true
+				}
 			}
+		}
+	}
+}
 
-			value["name"] = reflect.TypeOf(seg).String()
-			value["instance"] = strconv.Itoa(index + 1)
-
-			if c.DefaultSeparator.Hide != nil {
-				value["separator"] = !*c.DefaultSeparator.Hide
+// appendBlocks appends the last blocks of every Widget to blocks, filling in
+// their defaults, and adds them to routes
+func (c *Config) appendBlocks(blocks []StatusBlock, states []*widgetState, routes *clickRoutes) []StatusBlock {
+	for index, ws := range states {
+		for block, s := range ws.last {
+			if ws.stale {
+				s.Color = c.StaleColor
 			}
-			if c.DefaultSeparator.Width != nil {
-				value["separator_block_width"] = *c.DefaultSeparator.Width
+			if s.Name == "" {
+				s.Name = ws.name
 			}
-			if s.Separator.Hide != nil {
-				value["separator"] = !*s.Separator.Hide
+			if s.Instance == "" {
+				s.Instance = ws.instance(index, block)
 			}
-			if s.Separator.Width != nil {
-				value["separator_block_width"] = *s.Separator.Width
-			}
-
-			value["full_text"] = s.FullText
-			if s.ShortText != "" {
-				value["short_text"] = s.ShortText
+			if s.Separator.Hide == nil {
+				s.Separator.Hide = c.DefaultSeparator.Hide
 			}
-			encodeColor(value, "color", s.Color)
-			encodeColor(value, "background", s.Background)
-			encodeColor(value, "border", s.Border)
-			if s.MinWidth != 0 {
-				value["min_width"] = s.MinWidth
+			if s.Separator.Width == nil {
+				s.Separator.Width = c.DefaultSeparator.Width
 			}
-			if s.Align != "" && s.Align != AlignLeft {
-				value["align"] = string(s.Align)
-			}
-			if s.Urgent {
-				value["urgent"] = true
-			}
-			if s.Markup != "" && s.Markup != MarkupNone {
-				value["markup"] = string(s.Markup)
+			routes.add(s.Name, s.Instance, index, block)
+			blocks = append(blocks, s)
+		}
+	}
+	return blocks
+}
+
+// reload rebuilds the Widgets from ConfigFile, reusing the states of Widgets
+// whose configuration did not change, and retiring the states of Widgets that
+// were removed. If the file cannot be loaded c and states are left unchanged
+func (c *Config) reload(states []*widgetState, updater Updater, paused bool) ([]*widgetState, error) {
+	old := map[string][]*widgetState{}
+	for _, ws := range states {
+		if ws.key != "" {
+			old[ws.key] = append(old[ws.key], ws)
+		}
+	}
+	reused := map[int]*widgetState{}
+	load := loadConfig
+	if c.i3blocks {
+		load = loadI3blocksConfig
+	}
+	nc, err := load(c.ConfigFile, func(index int, wc *WidgetConfig) Widget {
+		key := wc.key()
+		matches := old[key]
+		if len(matches) == 0 {
+			return nil
+		}
+		old[key] = matches[1:]
+		reused[index] = matches[0]
+		return matches[0].widget
+	})
+	if err != nil {
+		return states, err
+	}
+
+	// these cannot change once the bar is running
+	nc.Renderer = c.Renderer
+	nc.Once = c.Once
+	nc.StopSignal = c.StopSignal
+	nc.ContSignal = c.ContSignal
+	nc.DontWatchBinary = c.DontWatchBinary
+	nc.ControlSocket = c.ControlSocket
+	nc.Supervise = c.Supervise
+	*c = nc.withDefaults()
+
+	keys := c.widgetKeys()
+	newStates := make([]*widgetState, len(c.Widgets))
+	for i, w := range c.Widgets {
+		ws, ok := reused[i]
+		if !ok {
+			ws = newWidgetState(w, keys[i], updater)
+			if paused {
+				pause(w)
 			}
+		}
+		// the default instances depend on the Widget's position
+		ws.instances = nil
+		newStates[i] = ws
+	}
+	for _, matches := range old {
+		for _, ws := range matches {
+			ws.retire()
+		}
+	}
+	return newStates, nil
+}
+
+// widgetState tracks a single Widget between frames. Each Widget has it's own
+// goroutine that calls Status, so a slow Widget does not hold up the others.
+// Every other method is called on the render goroutine, but never while a call
+// to Status is in flight; see do
+type widgetState struct {
+	widget Widget
+
+	// key identifies the Widget's configuration, if it was loaded from a
+	// config file
+	key string
+
+	update Updater
+
+	// name and instances are the default name and instance strings
+	name      string
+	instances []string
+
+	requests chan []StatusBlock
+	results  chan statusResult
+	pending  bool
+
+	// last is the most recent blocks returned by the Widget. spare is the
+	// buffer that will be filled by the next call to Status
+	last  []StatusBlock
+	spare []StatusBlock
+	stale bool
+
+	// failed is a panic from a method other than Status
+	failed error
+
+	// deferred are calls waiting for Status to return
+	deferred []func()
+}
+
+type statusResult struct {
+	blocks []StatusBlock
+	err    error
+}
+
+// newWidgetState starts calling Status on w, and passes it updater
+func newWidgetState(w Widget, key string, updater Updater) *widgetState {
+	setUpdater(w, updater)
+	ws := &widgetState{
+		widget:   w,
+		key:      key,
+		update:   updater,
+		name:     reflect.TypeOf(w).String(),
+		requests: make(chan []StatusBlock),
+		results:  make(chan statusResult, 1),
+	}
+	go ws.work()
+	return ws
+}
 
-			out = append(out, value)
+func (w *widgetState) work() {
+	for buf := range w.requests {
+		sbs, err := w.status(buf)
+		w.results <- statusResult{sbs, err}
+	}
+}
+
+// status calls Status, converting a panic into an error
+func (w *widgetState) status(buf []StatusBlock) (sbs []StatusBlock, err error) {
+	defer recoverPanic(w.name, &err)
+	return appendStatusBlocks(buf[:0], w.widget)
+}
+
+// instance returns the default instance string for a block
+func (w *widgetState) instance(index, block int) string {
+	if !isMultiWidget(w.widget) {
+		if w.instances == nil {
+			w.instances = []string{strconv.Itoa(index + 1)}
 		}
-		err := enc.Encode(out)
-		if err != nil {
-			panic(fmt.Errorf("unable to write output: %v", err))
+		return w.instances[0]
+	}
+	for len(w.instances) <= block {
+		instance := strconv.Itoa(index+1) + "." + strconv.Itoa(len(w.instances)+1)
+		w.instances = append(w.instances, instance)
+	}
+	return w.instances[block]
+}
+
+// start requests a call to Status, unless a previous call has not returned
+// yet. It returns true if a call was requested
+func (w *widgetState) start() bool {
+	if w.pending {
+		return false
+	}
+	w.pending = true
+	w.requests <- w.spare
+	return true
+}
+
+// do calls fn now if Status is not running, or after it returns otherwise
+func (w *widgetState) do(fn func()) {
+	if w.pending {
+		w.deferred = append(w.deferred, fn)
+		return
+	}
+	w.call(fn)
+}
+
+// call calls fn. If it panics the error is shown instead of the result of the
+// next call to Status
+func (w *widgetState) call(fn func()) {
+	err := w.try(fn)
+	if err != nil {
+		w.failed = err
+		w.update()
+	}
+}
+
+func (w *widgetState) try(fn func()) (err error) {
+	defer recoverPanic(w.name, &err)
+	fn()
+	return nil
+}
+
+func (w *widgetState) pause() {
+	pause(w.widget)
+}
+
+func (w *widgetState) resume() {
+	resume(w.widget)
+}
+
+// retire stops the Widget's goroutine, and closes the Widget once any pending
+// call to Status returns. Deferred calls are dropped
+func (w *widgetState) retire() {
+	close(w.requests)
+	if !w.pending {
+		w.close()
+		return
+	}
+	go func() {
+		<-w.results
+		w.close()
+	}()
+}
+
+func (w *widgetState) close() {
+	var err error
+	perr := w.try(func() {
+		err = closeWidget(w.widget)
+	})
+	if perr != nil {
+		err = perr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to close %v: %v\n", w.name, err)
+	}
+}
+
+func (w *widgetState) finish(r statusResult) {
+	w.pending = false
+	w.stale = false
+	w.spare, w.last = w.last, r.blocks
+	if r.err == nil {
+		r.err = w.failed
+	}
+	w.failed = nil
+	if r.err != nil {
+		w.last = append(w.last[:0], errorBlock(r.err))
+	}
+	for i, fn := range w.deferred {
+		w.call(fn)
+		w.deferred[i] = nil
+	}
+	w.deferred = w.deferred[:0]
+}
+
+// errorBlock renders an error returned by a Widget
+func errorBlock(err error) StatusBlock {
+	return StatusBlock{
+		FullText: fmt.Sprintf("error: %v", err),
+		Urgent:   true,
+	}
+}
+
+// A collector collects the StatusBlocks of a set of Widgets
+type collector struct {
+	states []*widgetState
+	timer  *time.Timer
+
+	// started is set for each Widget whose call to Status was requested by
+	// the current collect
+	started []bool
+}
+
+func newCollector(states []*widgetState) *collector {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return &collector{
+		states: states,
+		timer:  timer,
+	}
+}
+
+// collect calls Status on every Widget concurrently, and waits up to timeout
+// for them to return. Widgets which do not return in time are marked stale and
+// keep their last blocks. Widgets still in a call from an earlier collect are
+// not waited for, so a Widget which hangs only delays the frame it hung in
+func (c *collector) collect(timeout time.Duration) {
+	c.started = c.started[:0]
+	for _, ws := range c.states {
+		c.started = append(c.started, ws.start())
+	}
+
+	c.timer.Reset(timeout)
+	expired := false
+	for i, ws := range c.states {
+		if !expired && c.started[i] {
+			select {
+			case r := <-ws.results:
+				ws.finish(r)
+				continue
+			case <-c.timer.C:
+				expired = true
+			}
 		}
-		_, err = os.Stdout.WriteString(",\n")
-		if err != nil {
-			panic(fmt.Errorf("unable to write output: %v", err))
+		select {
+		case r := <-ws.results:
+			ws.finish(r)
+		default:
+			ws.stale = true
 		}
-		out = out[:0]
+	}
+	if !expired && !c.timer.Stop() {
+		<-c.timer.C
+	}
+}
 
+// waitIdle waits up to timeout for any pending calls to Status to return
+func waitIdle(states []*widgetState, timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for _, ws := range states {
+		if !ws.pending {
+			continue
+		}
 		select {
-		case <-tick:
-		case <-click:
+		case r := <-ws.results:
+			ws.finish(r)
+		case <-deadline.C:
+			return
 		}
 	}
 }
 
-func encodeColor(value map[string]interface{}, key string, c color.Color) {
-	if c == nil {
-		return
+// closeAll closes every Widget which is not in a call to Status
+func closeAll(states []*widgetState) {
+	for _, ws := range states {
+		if !ws.pending {
+			ws.close()
+		}
+	}
+}
+
+// close stops the goroutines calling Status
+func (c *collector) close() {
+	c.timer.Stop()
+	for _, ws := range c.states {
+		close(ws.requests)
+	}
+}
+
+// clickRoutes maps the name and instance of each block in the last frame to
+// the Widget that rendered it
+type clickRoutes struct {
+	routes map[blockID]blockRef
+}
+
+type blockID struct {
+	name, instance string
+}
+
+type blockRef struct {
+	widget, block int
+}
+
+func (r *clickRoutes) reset() {
+	if r.routes == nil {
+		r.routes = map[blockID]blockRef{}
+	}
+	for k := range r.routes {
+		delete(r.routes, k)
+	}
+}
+
+func (r *clickRoutes) add(name, instance string, widget, block int) {
+	r.routes[blockID{name, instance}] = blockRef{widget, block}
+}
+
+// lookup finds the Widget that rendered a block. If the block was not in the
+// last frame, such as when a click arrives right after a Restart, the instance
+// is parsed as a position
+func (r *clickRoutes) lookup(name, instance string) (widget, block int, ok bool) {
+	ref, ok := r.routes[blockID{name, instance}]
+	if ok {
+		return ref.widget, ref.block, true
+	}
+	return parseInstance(instance)
+}
+
+// parseInstance parses an instance string in the form "widget" or
+// "widget.block" into zero based indexes
+func parseInstance(instance string) (widget, block int, ok bool) {
+	blockStr := ""
+	if dot := strings.IndexByte(instance, '.'); dot != -1 {
+		instance, blockStr = instance[:dot], instance[dot+1:]
+	}
+	widget, err := strconv.Atoi(instance)
+	if err != nil || widget < 1 {
+		return 0, 0, false
+	}
+	block = 1
+	if blockStr != "" {
+		block, err = strconv.Atoi(blockStr)
+		if err != nil || block < 1 {
+			return 0, 0, false
+		}
 	}
-	r, g, b, _ := c.RGBA()
-	value[key] = fmt.Sprintf("#%02X%02X%02X", r>>8, g>>8, b>>8)
+	return widget - 1, block - 1, true
 }
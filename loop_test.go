@@ -0,0 +1,269 @@
+package my3status
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// runBar runs c with pipes for it's input and output, returning the click
+// input, a channel of the frames it writes, and a channel of Run's result
+func runBar(ctx context.Context, t *testing.T, c Config) (io.WriteCloser, <-chan []StatusBlock, <-chan error) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		err := c.Run(ctx, inR, outW)
+		outW.Close()
+		done <- err
+	}()
+
+	frames := make(chan []StatusBlock, 16)
+	go func() {
+		defer close(frames)
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			line := bytes.TrimSuffix(scanner.Bytes(), []byte(","))
+			// the first frame follows the header, and the start of the
+			// infinite array
+			start := bytes.Index(line, []byte("[{"))
+			if start == -1 {
+				continue
+			}
+			line = line[start:]
+			var blocks []StatusBlock
+			err := json.Unmarshal(line, &blocks)
+			if err != nil {
+				t.Errorf("invalid frame %q: %v", line, err)
+				return
+			}
+			frames <- blocks
+		}
+	}()
+	return inW, frames, done
+}
+
+// waitFor returns the first frame whose first block has fullText
+func waitFor(t *testing.T, frames <-chan []StatusBlock, fullText string) StatusBlock {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case blocks, ok := <-frames:
+			if !ok {
+				t.Fatalf("output closed while waiting for %q", fullText)
+			}
+			if len(blocks) > 0 && blocks[0].FullText == fullText {
+				return blocks[0]
+			}
+		case <-timeout:
+			t.Fatalf("no frame showing %q", fullText)
+		}
+	}
+}
+
+func TestRunClicks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in, frames, done := runBar(ctx, t, Config{
+		Widgets: []Widget{
+			Switcher{
+				StatusBlock{FullText: "a"},
+				StatusBlock{FullText: "b"},
+				StatusBlock{FullText: "c"},
+			},
+		},
+		// ticks race with the clicks
+		Interval:          5 * time.Millisecond,
+		MinUpdateInterval: time.Millisecond,
+		DontWatchBinary:   true,
+	})
+
+	block := waitFor(t, frames, "a")
+	_, err := io.WriteString(in, "[\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, next := range []string{"b", "c", "a"} {
+		data, err := json.Marshal(ClickEvent{
+			Name:     block.Name,
+			Instance: block.Instance,
+			Button:   ButtonLeft,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = in.Write(append(data, ",\n"...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = waitFor(t, frames, next)
+	}
+
+	// Run returns once the clicks reach EOF
+	in.Close()
+	go func() {
+		for range frames {
+		}
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after EOF")
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	closer := &closeRecorder{}
+	in, frames, done := runBar(ctx, t, Config{
+		Widgets: []Widget{
+			Switcher{
+				StatusBlock{FullText: "a"},
+				closer,
+			},
+		},
+		DontWatchBinary: true,
+	})
+	defer in.Close()
+
+	waitFor(t, frames, "a")
+	cancel()
+	go func() {
+		for range frames {
+		}
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after it's context was cancelled")
+	}
+	if !closer.closed {
+		t.Error("the widgets were not closed")
+	}
+}
+
+type closeRecorder struct {
+	StatusBlock
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+// hangingWidget's Status does not return until release is closed
+type hangingWidget struct {
+	release chan struct{}
+}
+
+func (h *hangingWidget) Status() (StatusBlock, error) {
+	<-h.release
+	return StatusBlock{FullText: "hung"}, nil
+}
+
+func TestRunHangingWidget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hanging := &hangingWidget{release: make(chan struct{})}
+	in, frames, done := runBar(ctx, t, Config{
+		Widgets: []Widget{
+			Switcher{
+				StatusBlock{FullText: "a"},
+				StatusBlock{FullText: "b"},
+			},
+			hanging,
+		},
+		Interval:        time.Hour,
+		Timeout:         2 * time.Second,
+		DontWatchBinary: true,
+	})
+
+	// the first frame waits for the hanging widget
+	block := waitFor(t, frames, "a")
+	data, err := json.Marshal(ClickEvent{
+		Name:     block.Name,
+		Instance: block.Instance,
+		Button:   ButtonLeft,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clicked := time.Now()
+	_, err = in.Write(append(append([]byte("[\n"), data...), '\n'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, frames, "b")
+	if since := time.Since(clicked); since > time.Second {
+		t.Errorf("the click took %v to appear, waiting for the hanging widget", since)
+	}
+
+	close(hanging.release)
+	in.Close()
+	go func() {
+		for range frames {
+		}
+	}()
+	<-done
+}
+
+func TestRunControlSocketInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "my3status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// another bar's socket
+	path := filepath.Join(dir, "control.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in, frames, done := runBar(ctx, t, Config{
+		Widgets: []Widget{
+			StatusBlock{FullText: "a"},
+		},
+		ControlSocket:   path,
+		DontWatchBinary: true,
+	})
+	waitFor(t, frames, "a")
+
+	in.Close()
+	go func() {
+		for range frames {
+		}
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after EOF")
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
-	"strings"
 )
 
 // Memory displays the amount of Memory Available/Total in gb
 type Memory struct {
 	meminfo ProcFile
+
+	text       textBuffer
+	percentage *int
 }
 
 func (m *Memory) Status() (StatusBlock, error) {
@@ -18,12 +20,12 @@ func (m *Memory) Status() (StatusBlock, error) {
 		return StatusBlock{}, err
 	}
 
-	total, err := readMemInfoLine(meminfo, "MemTotal")
+	total, err := readMemInfoLine(meminfo, "MemTotal:")
 	if err != nil {
 		return StatusBlock{}, err
 	}
 
-	free, err := readMemInfoLine(meminfo, "MemAvailable")
+	free, err := readMemInfoLine(meminfo, "MemAvailable:")
 	if err != nil {
 		return StatusBlock{}, err
 	}
@@ -31,13 +33,27 @@ func (m *Memory) Status() (StatusBlock, error) {
 	used := total - free
 
 	const gib = 1024 * 1024 * 1024
+	percentage := int(used * 100 / total)
+	if m.percentage == nil || *m.percentage != percentage {
+		// the last pointer may still be in a frame being rendered, so it is
+		// replaced rather than changed
+		p := percentage
+		m.percentage = &p
+	}
+
+	m.text.buf = strconv.AppendFloat(m.text.buf[:0], float64(used)/gib, 'f', 1, 64)
+	m.text.buf = append(m.text.buf, '/')
+	m.text.buf = strconv.AppendFloat(m.text.buf, float64(total)/gib, 'f', 1, 64)
+	m.text.buf = append(m.text.buf, 'G')
 	return StatusBlock{
-		FullText: fmt.Sprintf("%.1f/%.1fG", float64(used)/gib, float64(total)/gib),
+		FullText:   m.text.String(),
+		Percentage: m.percentage,
 	}, nil
 }
 
+// readMemInfoLine reads the value of the line starting with name, such as
+// "MemTotal:", in bytes
 func readMemInfoLine(meminfo []byte, name string) (uint64, error) {
-	name += ":"
 	idx := bytes.Index(meminfo, []byte(name))
 	if idx == -1 {
 		return 0, fmt.Errorf("Memory: unable to find %q block", name)
@@ -57,20 +73,53 @@ func readMemInfoLine(meminfo []byte, name string) (uint64, error) {
 	if space != -1 {
 		end := line[space+1:]
 		line = line[:space]
-		switch strings.ToLower(string(end)) {
-		case "gib", "gb":
+		switch {
+		case equalFold(end, "gib"), equalFold(end, "gb"):
 			size *= 1024
 			fallthrough
-		case "mib", "mb":
+		case equalFold(end, "mib"), equalFold(end, "mb"):
 			size *= 1024
 			fallthrough
-		case "kib", "kb":
+		case equalFold(end, "kib"), equalFold(end, "kb"):
 			size *= 1024
 		}
 	}
-	val, err := strconv.ParseUint(string(line), 10, 64)
-	if err != nil {
-		return 0, fmt.Errorf("Memory: err parsing %q block: %v", name, err)
+	val, ok := parseUint(line)
+	if !ok {
+		return 0, fmt.Errorf("Memory: err parsing %q block: invalid number %q", name, line)
 	}
 	return val * size, nil
 }
+
+// equalFold is strings.EqualFold for a []byte, without converting it to a
+// string
+func equalFold(b []byte, s string) bool {
+	if len(b) != len(s) {
+		return false
+	}
+	for i := range b {
+		c := b[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseUint parses a decimal number, without converting it to a string
+func parseUint(b []byte) (uint64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	v := uint64(0)
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		v = v*10 + uint64(c-'0')
+	}
+	return v, true
+}
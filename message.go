@@ -0,0 +1,249 @@
+package my3status
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Message displays text posted by scripts, either by writing lines to a FIFO
+// or with the control socket's ControlPost command. Messages stack up, with
+// the most recent one shown. Clicking the block dismisses it, showing the one
+// before it
+type Message struct {
+	// Name is the block's name, and the name messages are posted to. If unset
+	// "message" is used
+	Name string
+
+	// FIFO is the path of a FIFO to read messages from, which is created if it
+	// does not exist. Each line is either plain text, or a PostedMessage as
+	// JSON
+	FIFO string
+
+	// TTL is how long messages are shown if they do not set their own. If
+	// unset messages are shown until they are dismissed
+	TTL time.Duration
+
+	mu       sync.Mutex
+	started  bool
+	messages []postedMessage
+	update   Updater
+	fifo     *os.File
+	err      error
+}
+
+// A PostedMessage is a message posted to a Message widget
+type PostedMessage struct {
+	Text string `json:"text"`
+
+	// Color is the color of the text, as "#RRGGBB"
+	Color string `json:"color,omitempty"`
+
+	Urgent bool `json:"urgent,omitempty"`
+
+	// TTL is how long the message is shown for, such as "30s". If unset the
+	// Message's TTL is used
+	TTL string `json:"ttl,omitempty"`
+}
+
+type postedMessage struct {
+	text    string
+	color   color.Color
+	urgent  bool
+	expires time.Time
+}
+
+var messagesLock = &sync.Mutex{}
+var messageWidgets = map[string]*Message{}
+
+// PostMessage posts msg to the Message widget named name in this process
+func PostMessage(name string, msg PostedMessage) error {
+	messagesLock.Lock()
+	m, ok := messageWidgets[name]
+	messagesLock.Unlock()
+	if !ok {
+		return fmt.Errorf("no message widget named %q", name)
+	}
+	return m.Post(msg)
+}
+
+func (m *Message) name() string {
+	if m.Name == "" {
+		return "message"
+	}
+	return m.Name
+}
+
+// start registers the widget and starts reading the FIFO. m.mu must be held
+func (m *Message) start() {
+	if m.started {
+		return
+	}
+	m.started = true
+
+	messagesLock.Lock()
+	messageWidgets[m.name()] = m
+	messagesLock.Unlock()
+
+	if m.FIFO == "" {
+		return
+	}
+	err := unix.Mkfifo(m.FIFO, 0600)
+	if err != nil && err != unix.EEXIST {
+		m.err = fmt.Errorf("unable to create %v: %v", m.FIFO, err)
+		return
+	}
+	// opening it for writing too means we never see EOF when a writer closes
+	fifo, err := os.OpenFile(m.FIFO, os.O_RDWR, 0)
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.fifo = fifo
+	go m.read(fifo)
+}
+
+func (m *Message) read(fifo *os.File) {
+	br := bufio.NewReader(fifo)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		msg := PostedMessage{
+			Text: line,
+		}
+		if strings.HasPrefix(line, "{") {
+			err = json.Unmarshal([]byte(line), &msg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v: invalid message: %v\n", m.FIFO, err)
+				continue
+			}
+		}
+		err = m.Post(msg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: invalid message: %v\n", m.FIFO, err)
+		}
+	}
+}
+
+// Post shows msg, and redraws the bar. It may be called from any goroutine
+func (m *Message) Post(msg PostedMessage) error {
+	pm := postedMessage{
+		text:   msg.Text,
+		urgent: msg.Urgent,
+	}
+	if msg.Color != "" {
+		c, err := parseColor(msg.Color)
+		if err != nil {
+			return err
+		}
+		pm.color = c
+	}
+	ttl := m.TTL
+	if msg.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(msg.TTL)
+		if err != nil {
+			return err
+		}
+	}
+	if ttl > 0 {
+		pm.expires = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	m.messages = append(m.messages, pm)
+	update := m.update
+	m.mu.Unlock()
+
+	if update != nil {
+		update()
+		if ttl > 0 {
+			// redraw once it expires rather than on the next tick
+			time.AfterFunc(ttl, update)
+		}
+	}
+	return nil
+}
+
+func (m *Message) SetUpdater(u Updater) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.update = u
+	m.start()
+}
+
+func (m *Message) Status() (StatusBlock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.start()
+	if m.err != nil {
+		return StatusBlock{}, m.err
+	}
+
+	now := time.Now()
+	kept := m.messages[:0]
+	for _, pm := range m.messages {
+		if pm.expires.IsZero() || pm.expires.After(now) {
+			kept = append(kept, pm)
+		}
+	}
+	m.messages = kept
+
+	block := StatusBlock{
+		Name: m.name(),
+	}
+	if len(m.messages) == 0 {
+		return block, nil
+	}
+	pm := m.messages[len(m.messages)-1]
+	block.FullText = pm.text
+	block.ShortText = pm.text
+	if len(m.messages) > 1 {
+		block.FullText += fmt.Sprintf(" (+%d)", len(m.messages)-1)
+	}
+	block.Color = pm.color
+	block.Urgent = pm.urgent
+	return block, nil
+}
+
+// Click dismisses the message being shown
+func (m *Message) Click(c ClickEvent) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.messages) == 0 {
+		return false
+	}
+	m.messages = m.messages[:len(m.messages)-1]
+	return true
+}
+
+// Close stops reading the FIFO, and stops accepting posted messages
+func (m *Message) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messagesLock.Lock()
+	if messageWidgets[m.name()] == m {
+		delete(messageWidgets, m.name())
+	}
+	messagesLock.Unlock()
+
+	if m.fifo != nil {
+		m.fifo.Close()
+		m.fifo = nil
+	}
+	return nil
+}
@@ -4,44 +4,98 @@ import (
 	"bufio"
 	"fmt"
 	"os/exec"
+	"strings"
+	"sync"
 )
 
 type NvidiaTemperature struct {
 	Format string
-	live   bool
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
 	status StatusBlock
 	err    error
+	update Updater
+}
+
+func (t *NvidiaTemperature) SetUpdater(u Updater) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update = u
 }
 
 func (t *NvidiaTemperature) Status() (StatusBlock, error) {
-	if !t.live {
-		t.live = true
-
-		go func() {
-			cmd := exec.Command("nvidia-smi", "--query-gpu=temperature.gpu", "--format=csv,noheader", "-l", "1")
-			pipe, err := cmd.StdoutPipe()
-			if err != nil {
-				t.err = err
-				return
-			}
-			br := bufio.NewReader(pipe)
-			err = cmd.Start()
-			if err != nil {
-				t.err = err
-				return
-			}
-			for {
-				line, err := br.ReadString('\n')
-				if err != nil {
-					t.err = err
-					return
-				}
-
-				str := line[:len(line)-1]
-				t.status.FullText = fmt.Sprintf(t.Format, str)
-			}
-		}()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cmd == nil {
+		t.start()
 	}
 
 	return t.status, t.err
 }
+
+// start launches nvidia-smi in the background. t.mu must be held
+func (t *NvidiaTemperature) start() {
+	cmd := exec.Command("nvidia-smi", "--query-gpu=temperature.gpu", "--format=csv,noheader", "-l", "1")
+	t.cmd = cmd
+	t.err = nil
+
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		t.err = err
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		t.err = err
+		return
+	}
+	go t.read(cmd, bufio.NewReader(pipe))
+}
+
+func (t *NvidiaTemperature) read(cmd *exec.Cmd, br *bufio.Reader) {
+	defer cmd.Wait()
+	for {
+		line, err := br.ReadString('\n')
+
+		t.mu.Lock()
+		if t.cmd != cmd {
+			// we have been paused
+			t.mu.Unlock()
+			return
+		}
+		if err != nil {
+			t.err = err
+			t.mu.Unlock()
+			return
+		}
+		t.status.FullText = fmt.Sprintf(t.Format, strings.TrimSuffix(line, "\n"))
+		update := t.update
+		t.mu.Unlock()
+
+		if update != nil {
+			update()
+		}
+	}
+}
+
+// Pause kills nvidia-smi. It is started again by the next call to Status
+func (t *NvidiaTemperature) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cmd != nil && t.cmd.Process != nil {
+		t.cmd.Process.Kill()
+	}
+	t.cmd = nil
+}
+
+func (t *NvidiaTemperature) Resume() {
+}
+
+// Close kills nvidia-smi
+func (t *NvidiaTemperature) Close() error {
+	t.Pause()
+	return nil
+}
@@ -0,0 +1,216 @@
+package my3status
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// pangoStyle is the subset of pango text attributes understood by renderers
+// other than i3bar
+type pangoStyle struct {
+	Foreground     color.Color
+	Background     color.Color
+	Underline      bool
+	UnderlineColor color.Color
+	Bold           bool
+	Italic         bool
+	Strikethrough  bool
+}
+
+// A pangoSpan is a run of text with a single style
+type pangoSpan struct {
+	Text string
+	pangoStyle
+}
+
+// parsePango splits pango markup into runs of styled text. Unknown tags and
+// attributes are ignored, and malformed markup is treated as text
+func parsePango(markup string) []pangoSpan {
+	var spans []pangoSpan
+	stack := []pangoStyle{{}}
+	text := &strings.Builder{}
+	flush := func() {
+		if text.Len() > 0 {
+			spans = append(spans, pangoSpan{
+				Text:       text.String(),
+				pangoStyle: stack[len(stack)-1],
+			})
+			text.Reset()
+		}
+	}
+
+	for len(markup) > 0 {
+		switch markup[0] {
+		case '<':
+			end := strings.IndexByte(markup, '>')
+			if end == -1 {
+				text.WriteString(markup)
+				markup = ""
+				continue
+			}
+			tag := markup[1:end]
+			markup = markup[end+1:]
+			flush()
+			if strings.HasPrefix(tag, "/") {
+				if len(stack) > 1 {
+					stack = stack[:len(stack)-1]
+				}
+				continue
+			}
+			style := stack[len(stack)-1]
+			name, attrs := tag, ""
+			if sp := strings.IndexAny(tag, " \t\n"); sp != -1 {
+				name, attrs = tag[:sp], tag[sp+1:]
+			}
+			switch name {
+			case "b":
+				style.Bold = true
+			case "i":
+				style.Italic = true
+			case "u":
+				style.Underline = true
+			case "s":
+				style.Strikethrough = true
+			case "span":
+				parsePangoAttrs(&style, attrs)
+			}
+			if strings.HasSuffix(tag, "/") {
+				continue
+			}
+			stack = append(stack, style)
+		case '&':
+			end := strings.IndexByte(markup, ';')
+			if end == -1 {
+				text.WriteString(markup)
+				markup = ""
+				continue
+			}
+			text.WriteString(unescapeEntity(markup[1:end]))
+			markup = markup[end+1:]
+		default:
+			next := strings.IndexAny(markup, "<&")
+			if next == -1 {
+				next = len(markup)
+			}
+			text.WriteString(markup[:next])
+			markup = markup[next:]
+		}
+	}
+	flush()
+	return spans
+}
+
+// parsePangoAttrs applies the attributes of a span tag to style
+func parsePangoAttrs(style *pangoStyle, attrs string) {
+	for {
+		attrs = strings.TrimLeft(attrs, " \t\n/")
+		eq := strings.IndexByte(attrs, '=')
+		if eq == -1 || eq+1 >= len(attrs) {
+			return
+		}
+		key := strings.TrimSpace(attrs[:eq])
+		quote := attrs[eq+1]
+		if quote != '"' && quote != '\'' {
+			return
+		}
+		end := strings.IndexByte(attrs[eq+2:], quote)
+		if end == -1 {
+			return
+		}
+		value := attrs[eq+2 : eq+2+end]
+		attrs = attrs[eq+2+end+1:]
+
+		switch key {
+		case "foreground", "fgcolor", "color":
+			style.Foreground = parseColorOrNil(value)
+		case "background", "bgcolor":
+			style.Background = parseColorOrNil(value)
+		case "underline":
+			style.Underline = value != "none"
+		case "underline_color":
+			style.UnderlineColor = parseColorOrNil(value)
+		case "weight", "font_weight":
+			style.Bold = value == "bold" || value == "heavy" || value == "ultrabold"
+		case "style", "font_style":
+			style.Italic = value == "italic" || value == "oblique"
+		case "strikethrough":
+			style.Strikethrough = value == "true"
+		}
+	}
+}
+
+func unescapeEntity(entity string) string {
+	switch entity {
+	case "amp":
+		return "&"
+	case "lt":
+		return "<"
+	case "gt":
+		return ">"
+	case "quot":
+		return "\""
+	case "apos":
+		return "'"
+	}
+	if strings.HasPrefix(entity, "#") {
+		var r uint64
+		var err error
+		if strings.HasPrefix(entity, "#x") {
+			r, err = strconv.ParseUint(entity[2:], 16, 32)
+		} else {
+			r, err = strconv.ParseUint(entity[1:], 10, 32)
+		}
+		if err == nil {
+			return string(rune(r))
+		}
+	}
+	return "&" + entity + ";"
+}
+
+// plainText returns the text of a StatusBlock with any markup removed
+func plainText(text string, markup Markup) string {
+	if markup != MarkupPango {
+		return text
+	}
+	b := &strings.Builder{}
+	for _, span := range parsePango(text) {
+		b.WriteString(span.Text)
+	}
+	return b.String()
+}
+
+// parseColorOrNil parses a color in the form #RGB, #RRGGBB or #RRGGBBAA,
+// returning nil if it is invalid
+func parseColorOrNil(s string) color.Color {
+	c, err := parseColor(s)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// parseColor parses a color in the form #RGB, #RRGGBB or #RRGGBBAA
+func parseColor(s string) (color.Color, error) {
+	if !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	digits := s[1:]
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	if len(digits) == 6 {
+		digits += "FF"
+	}
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) != 8 {
+		return nil, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	return color.NRGBA{
+		R: uint8(v >> 24),
+		G: uint8(v >> 16),
+		B: uint8(v >> 8),
+		A: uint8(v),
+	}, nil
+}
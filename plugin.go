@@ -0,0 +1,406 @@
+package my3status
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PluginVersion is the version of the plugin protocol
+const PluginVersion = 1
+
+// Methods of the plugin protocol. A plugin is a long running process which
+// exchanges JSON-RPC 2.0 messages with the bar, one per line, over it's stdin
+// and stdout. Anything it writes to stderr is logged.
+//
+// The bar starts by sending a PluginMethodInitialize request, which the plugin
+// must answer with a PluginInitializeResult. The plugin then sends a
+// PluginMethodUpdate notification whenever it's blocks change, and at least
+// once every Timeout, so the bar knows it is still working. Clicks are sent to
+// the plugin as PluginMethodClick notifications. When the bar no longer needs
+// the plugin it closes the plugin's stdin, and the plugin should exit.
+//
+// Unknown notifications are ignored, and unknown requests are answered with a
+// method not found error, so either side may add methods. A line which is not
+// JSON ends the plugin.
+const (
+	// PluginMethodInitialize is a request from the bar with
+	// PluginInitializeParams
+	PluginMethodInitialize = "initialize"
+
+	// PluginMethodUpdate is a notification from the plugin with
+	// PluginUpdateParams
+	PluginMethodUpdate = "update"
+
+	// PluginMethodClick is a notification from the bar with a ClickEvent
+	PluginMethodClick = "click"
+)
+
+// pluginMethodNotFound is the JSON-RPC 2.0 error code for unknown methods
+const pluginMethodNotFound = -32601
+
+// A PluginMessage is a JSON-RPC 2.0 request, response or notification
+type PluginMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *PluginError     `json:"error,omitempty"`
+}
+
+// A PluginError is a JSON-RPC 2.0 error
+type PluginError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("%v (%d)", e.Message, e.Code)
+}
+
+// PluginInitializeParams are the params of PluginMethodInitialize
+type PluginInitializeParams struct {
+	Version int `json:"version"`
+
+	// Timeout is the longest the plugin may go without sending an update, in
+	// seconds
+	Timeout float64 `json:"timeout"`
+}
+
+// PluginInitializeResult is a plugin's response to PluginMethodInitialize
+type PluginInitializeResult struct {
+	// Version is the protocol version the plugin speaks, which must be
+	// PluginVersion
+	Version int `json:"version"`
+}
+
+// PluginUpdateParams are the params of PluginMethodUpdate
+type PluginUpdateParams struct {
+	// Blocks are the plugin's blocks, in the i3bar protocol's format
+	Blocks []StatusBlock `json:"blocks"`
+
+	// Error, if set, is shown instead of the blocks
+	Error string `json:"error,omitempty"`
+}
+
+// PluginWidget runs a plugin, displaying the blocks it sends. See
+// PluginMethodInitialize for the protocol. If the plugin exits it is restarted,
+// waiting longer after each consecutive failure
+type PluginWidget struct {
+	// Command is the plugin's executable and it's arguments
+	Command []string
+
+	// Timeout is the longest the plugin may go without sending an update
+	// before it's blocks are stale. If unset 30 seconds is used
+	Timeout time.Duration
+
+	// StaleColor is the color of stale blocks. If unset a dim grey is used
+	StaleColor color.Color
+
+	mu       sync.Mutex
+	started  bool
+	closed   bool
+	cmd      *exec.Cmd
+	stdin    *stdinWriter
+	blocks   []StatusBlock
+	err      error
+	last     time.Time
+	stale    *time.Timer
+	backoff  time.Duration
+	runStart time.Time
+	update   Updater
+}
+
+func (p *PluginWidget) timeout() time.Duration {
+	if p.Timeout == 0 {
+		return 30 * time.Second
+	}
+	return p.Timeout
+}
+
+func (p *PluginWidget) SetUpdater(u Updater) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.update = u
+}
+
+func (p *PluginWidget) Status() (StatusBlock, error) {
+	blocks, err := p.StatusBlocks()
+	if err != nil || len(blocks) == 0 {
+		return StatusBlock{}, err
+	}
+	return blocks[0], nil
+}
+
+func (p *PluginWidget) StatusBlocks() ([]StatusBlock, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.started {
+		p.started = true
+		p.start()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	blocks := append([]StatusBlock{}, p.blocks...)
+	if p.cmd == nil || time.Since(p.last) > p.timeout() {
+		staleColor := p.StaleColor
+		if staleColor == nil {
+			staleColor = color.Gray{Y: 0x7F}
+		}
+		for i := range blocks {
+			blocks[i].Color = staleColor
+		}
+	}
+	return blocks, nil
+}
+
+// Click sends the click to the plugin
+func (p *PluginWidget) Click(c ClickEvent) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.call(PluginMethodClick, nil, c)
+	return false
+}
+
+// call sends a request to the plugin, or a notification if id is nil. p.mu
+// must be held
+func (p *PluginWidget) call(method string, id *json.RawMessage, params interface{}) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	p.send(PluginMessage{
+		ID:     id,
+		Method: method,
+		Params: data,
+	})
+}
+
+// send writes a message to the plugin, if it is running. p.mu must be held
+func (p *PluginWidget) send(msg PluginMessage) {
+	if p.stdin == nil {
+		return
+	}
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	p.stdin.send(append(data, '\n'))
+}
+
+// start starts the plugin. p.mu must be held
+func (p *PluginWidget) start() {
+	if p.closed {
+		return
+	}
+	if len(p.Command) == 0 {
+		p.err = fmt.Errorf("plugin has no command")
+		return
+	}
+	p.runStart = time.Now()
+	cmd := exec.Command(p.Command[0], p.Command[1:]...)
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		p.err = err
+		return
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		p.err = err
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		p.failed(err)
+		return
+	}
+	trackProcessGroup(cmd.Process.Pid)
+	p.cmd = cmd
+	p.stdin = newStdinWriter(stdin)
+	p.last = time.Now()
+	p.resetStale()
+
+	id := json.RawMessage("1")
+	p.call(PluginMethodInitialize, &id, PluginInitializeParams{
+		Version: PluginVersion,
+		Timeout: p.timeout().Seconds(),
+	})
+	go p.read(cmd, stdout)
+}
+
+// resetStale redraws the bar when the plugin's blocks become stale. p.mu must
+// be held
+func (p *PluginWidget) resetStale() {
+	if p.stale != nil {
+		p.stale.Stop()
+	}
+	update := p.update
+	p.stale = time.AfterFunc(p.timeout()+time.Millisecond, func() {
+		if update != nil {
+			update()
+		}
+	})
+}
+
+// read handles messages from the plugin until it exits
+func (p *PluginWidget) read(cmd *exec.Cmd, stdout io.Reader) {
+	br := bufio.NewReader(stdout)
+	var err error
+	for err == nil {
+		var line []byte
+		line, err = br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		herr := p.handle(line)
+		if herr != nil {
+			// there is no telling where the next message starts
+			err = herr
+			killProcessGroup(cmd.Process.Pid)
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	werr := cmd.Wait()
+	untrackProcessGroup(cmd.Process.Pid)
+	if err == nil {
+		err = werr
+	}
+	if err == nil {
+		err = fmt.Errorf("plugin exited")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cmd = nil
+	if p.stdin != nil {
+		p.stdin.close()
+		p.stdin = nil
+	}
+	if p.stale != nil {
+		p.stale.Stop()
+	}
+	if p.closed {
+		return
+	}
+	p.failed(err)
+}
+
+// failed logs err, and starts the plugin again after a delay. p.mu must be
+// held
+func (p *PluginWidget) failed(err error) {
+	// the delay is reset if the plugin ran for a while before failing
+	if time.Since(p.runStart) > maxBackoff || p.backoff < minBackoff {
+		p.backoff = minBackoff
+	}
+	fmt.Fprintf(os.Stderr, "plugin %v: %v, restarting in %v\n", p.Command[0], err, p.backoff)
+	if len(p.blocks) == 0 {
+		p.err = err
+	}
+	time.AfterFunc(p.backoff, func() {
+		p.mu.Lock()
+		p.start()
+		p.mu.Unlock()
+	})
+	p.backoff *= 2
+	if p.backoff > maxBackoff {
+		p.backoff = maxBackoff
+	}
+	if p.update != nil {
+		go p.update()
+	}
+}
+
+// handle handles a single message from the plugin. An error is only returned
+// if the message is not JSON
+func (p *PluginWidget) handle(line []byte) error {
+	msg := PluginMessage{}
+	err := json.Unmarshal(line, &msg)
+	if err != nil {
+		return fmt.Errorf("invalid message: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case msg.Method == PluginMethodUpdate:
+		params := PluginUpdateParams{}
+		err := json.Unmarshal(msg.Params, &params)
+		if err != nil {
+			p.err = fmt.Errorf("invalid update: %v", err)
+			break
+		}
+		p.blocks = params.Blocks
+		p.err = nil
+		if params.Error != "" {
+			p.err = fmt.Errorf("%v", params.Error)
+		}
+	case msg.Method != "" && msg.ID != nil:
+		p.send(PluginMessage{
+			ID: msg.ID,
+			Error: &PluginError{
+				Code:    pluginMethodNotFound,
+				Message: fmt.Sprintf("unknown method %q", msg.Method),
+			},
+		})
+		return nil
+	case msg.Method != "" || msg.ID == nil:
+		// an unknown notification, or not a request or a response at all
+		return nil
+	case msg.Error != nil:
+		p.err = fmt.Errorf("initialize: %v", msg.Error)
+	default:
+		result := PluginInitializeResult{}
+		err := json.Unmarshal(msg.Result, &result)
+		if err != nil {
+			p.err = fmt.Errorf("invalid initialize result: %v", err)
+		} else if result.Version != PluginVersion {
+			p.err = fmt.Errorf("unsupported protocol version %v", result.Version)
+		}
+	}
+	p.last = time.Now()
+	p.resetStale()
+	if p.update != nil {
+		go p.update()
+	}
+	return nil
+}
+
+// Close stops the plugin. It is asked to exit by closing it's stdin, and is
+// killed if it does not
+func (p *PluginWidget) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.stale != nil {
+		p.stale.Stop()
+	}
+	if p.cmd == nil {
+		return nil
+	}
+	p.stdin.close()
+	p.stdin = nil
+	cmd := p.cmd
+	time.AfterFunc(time.Second, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.cmd == cmd {
+			killProcessGroup(cmd.Process.Pid)
+		}
+	})
+	return nil
+}
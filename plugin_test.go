@@ -0,0 +1,169 @@
+package my3status
+
+import (
+	"bufio"
+	"encoding/json"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// buildExamplePlugin builds cmd/exampleplugin into dir
+func buildExamplePlugin(t *testing.T, dir string) string {
+	binary := filepath.Join(dir, "exampleplugin")
+	out, err := exec.Command("go", "build", "-o", binary, "./cmd/exampleplugin").CombinedOutput()
+	if err != nil {
+		t.Fatalf("unable to build exampleplugin: %v\n%s", err, out)
+	}
+	return binary
+}
+
+// waitForBlocks polls p until ok returns true for it's blocks
+func waitForBlocks(t *testing.T, p *PluginWidget, what string, ok func([]StatusBlock) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		blocks, err := p.StatusBlocks()
+		if err == nil && ok(blocks) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("plugin did not become %v: %v %v", what, blocks, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func pluginPid(p *PluginWidget) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return 0
+	}
+	return p.cmd.Process.Pid
+}
+
+func isStale(blocks []StatusBlock) bool {
+	return len(blocks) == 1 && blocks[0].Color == (color.Gray{Y: 0x7F})
+}
+
+func showing(fullText string) func([]StatusBlock) bool {
+	return func(blocks []StatusBlock) bool {
+		return len(blocks) == 1 && blocks[0].FullText == fullText && blocks[0].Color == nil
+	}
+}
+
+func TestPluginWidgetStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "my3status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &PluginWidget{
+		Command: []string{buildExamplePlugin(t, dir)},
+		Timeout: 200 * time.Millisecond,
+	}
+	defer p.Close()
+	waitForBlocks(t, p, "fresh", showing("0 clicks"))
+
+	// a stopped plugin sends no heartbeats
+	pid := pluginPid(p)
+	syscall.Kill(pid, syscall.SIGSTOP)
+	defer syscall.Kill(pid, syscall.SIGCONT)
+	waitForBlocks(t, p, "stale", isStale)
+
+	syscall.Kill(pid, syscall.SIGCONT)
+	waitForBlocks(t, p, "fresh again", showing("0 clicks"))
+	if pluginPid(p) != pid {
+		t.Error("a stale plugin was restarted")
+	}
+}
+
+func TestPluginWidgetRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "my3status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &PluginWidget{
+		Command: []string{buildExamplePlugin(t, dir)},
+	}
+	defer p.Close()
+	waitForBlocks(t, p, "fresh", showing("0 clicks"))
+	p.Click(ClickEvent{Button: ButtonLeft})
+	waitForBlocks(t, p, "clicked", showing("1 clicks"))
+
+	pid := pluginPid(p)
+	killed := time.Now()
+	syscall.Kill(pid, syscall.SIGKILL)
+	// the last blocks are kept, but are stale until the plugin is back
+	waitForBlocks(t, p, "stale after exiting", func(blocks []StatusBlock) bool {
+		return isStale(blocks) && blocks[0].FullText == "1 clicks"
+	})
+	p.mu.Lock()
+	backoff := p.backoff
+	p.mu.Unlock()
+	if backoff != 2*minBackoff {
+		t.Errorf("backoff is %v after one failure, expected %v", backoff, 2*minBackoff)
+	}
+
+	// the new process starts counting from 0
+	waitForBlocks(t, p, "restarted", showing("0 clicks"))
+	if since := time.Since(killed); since < minBackoff {
+		t.Errorf("restarted after %v, before the %v backoff", since, minBackoff)
+	}
+	if pluginPid(p) == pid {
+		t.Error("the plugin was not restarted")
+	}
+}
+
+func TestPluginWidgetHandle(t *testing.T) {
+	r, w := io.Pipe()
+	p := &PluginWidget{
+		Command: []string{"plugin"},
+		stdin:   newStdinWriter(w),
+	}
+	defer p.Close()
+	responses := bufio.NewScanner(r)
+
+	err := p.handle([]byte(`{"jsonrpc":"2.0","method":"progress","params":{}}`))
+	if err != nil {
+		t.Errorf("an unknown notification was rejected: %v", err)
+	}
+	err = p.handle([]byte(`{"jsonrpc":"2.0","id":7,"method":"configure"}`))
+	if err != nil {
+		t.Errorf("an unknown request was rejected: %v", err)
+	}
+	// the notification was not answered, so this is the request's response
+	if !responses.Scan() {
+		t.Fatal("the unknown request was not answered")
+	}
+	msg := PluginMessage{}
+	err = json.Unmarshal(responses.Bytes(), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID == nil || string(*msg.ID) != "7" || msg.Error == nil || msg.Error.Code != -32601 {
+		t.Errorf("unexpected response %s", responses.Bytes())
+	}
+
+	err = p.handle([]byte(`{"jsonrpc":"2.0","method":"update","params":{"blocks":[{"full_text":"hi"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.blocks) != 1 || p.blocks[0].FullText != "hi" {
+		t.Errorf("update was not applied: %v", p.blocks)
+	}
+
+	err = p.handle([]byte(`{"jsonrpc":"2.0",`))
+	if err == nil {
+		t.Error("malformed JSON was accepted")
+	}
+}
@@ -0,0 +1,290 @@
+package my3status
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// Preview renders blocks on a single line of a terminal with 24-bit ANSI
+// colors, redrawing it in place. The keyboard selects blocks and sends them
+// ClickEvents. It is normally used through Config.Preview
+type Preview struct {
+	// Separator is drawn between blocks which do not hide their separator. If
+	// unset " │ " is used
+	Separator string
+
+	mu     sync.Mutex
+	out    io.Writer
+	blocks []StatusBlock
+
+	// selected is the index of the selected block. It follows the block's
+	// Name and Instance if other blocks appear or disappear before it
+	selected   int
+	selectedID blockID
+}
+
+const previewHelp = "←/→ select a block, 1-9 send a button, enter left clicks, q quits\r\n"
+
+func (p *Preview) AppendHeader(dst []byte, c Config) []byte {
+	dst = append(dst, "\x1b[2m"...)
+	dst = append(dst, previewHelp...)
+	return append(dst, "\x1b[0m"...)
+}
+
+func (p *Preview) AppendFrame(dst []byte, blocks []StatusBlock) []byte {
+	separator := p.Separator
+	if separator == "" {
+		separator = " │ "
+	}
+
+	p.mu.Lock()
+	p.blocks = append(p.blocks[:0], blocks...)
+	for i, s := range blocks {
+		if (blockID{s.Name, s.Instance}) == p.selectedID {
+			p.selected = i
+		}
+	}
+	selected := p.selected
+	p.mu.Unlock()
+
+	dst = append(dst, "\r\x1b[2K"...)
+	var prev *StatusBlock
+	for i, s := range blocks {
+		if s.FullText == "" {
+			continue
+		}
+		if prev != nil {
+			left, right, draw := separatorGap(*prev)
+			if draw {
+				dst = append(dst, "\x1b[2m"...)
+				dst = append(dst, separator...)
+				dst = append(dst, "\x1b[0m"...)
+			} else if left+right > 0 {
+				dst = append(dst, ' ')
+			}
+		}
+		prev = &blocks[i]
+
+		if i == selected {
+			dst = append(dst, "\x1b[1m[\x1b[0m"...)
+		}
+		dst = appendPreviewBlock(dst, s)
+		if i == selected {
+			dst = append(dst, "\x1b[1m]\x1b[0m"...)
+		}
+	}
+	return dst
+}
+
+func appendPreviewBlock(dst []byte, s StatusBlock) []byte {
+	base := pangoStyle{
+		Foreground: s.Color,
+		Background: s.Background,
+	}
+	reverse := false
+	if s.Urgent {
+		if base.Background == nil {
+			base.Background = color.RGBA{R: 0xFF, A: 0xFF}
+		} else {
+			reverse = true
+		}
+	}
+
+	if s.Markup != MarkupPango {
+		return appendANSIText(dst, base, reverse, s.FullText)
+	}
+	for _, span := range parsePango(s.FullText) {
+		style := span.pangoStyle
+		if style.Foreground == nil {
+			style.Foreground = base.Foreground
+		}
+		if style.Background == nil {
+			style.Background = base.Background
+		}
+		dst = appendANSIText(dst, style, reverse, span.Text)
+	}
+	return dst
+}
+
+// appendANSIText appends text with SGR escape codes for style, followed by a
+// reset
+func appendANSIText(dst []byte, style pangoStyle, reverse bool, text string) []byte {
+	if style.Foreground != nil {
+		dst = appendANSIColor(dst, "38", style.Foreground)
+	}
+	if style.Background != nil {
+		dst = appendANSIColor(dst, "48", style.Background)
+	}
+	if style.Underline || style.UnderlineColor != nil {
+		dst = append(dst, "\x1b[4m"...)
+	}
+	if style.UnderlineColor != nil {
+		dst = appendANSIColor(dst, "58", style.UnderlineColor)
+	}
+	if style.Bold {
+		dst = append(dst, "\x1b[1m"...)
+	}
+	if style.Italic {
+		dst = append(dst, "\x1b[3m"...)
+	}
+	if style.Strikethrough {
+		dst = append(dst, "\x1b[9m"...)
+	}
+	if reverse {
+		dst = append(dst, "\x1b[7m"...)
+	}
+	for _, r := range text {
+		if r < ' ' || r == 0x7F {
+			r = ' '
+		}
+		dst = append(dst, string(r)...)
+	}
+	return append(dst, "\x1b[0m"...)
+}
+
+// appendANSIColor appends a 24-bit color escape, such as \x1b[38;2;R;G;Bm
+func appendANSIColor(dst []byte, kind string, c color.Color) []byte {
+	r, g, b, _ := opaque(c).RGBA()
+	dst = append(dst, "\x1b["...)
+	dst = append(dst, kind...)
+	dst = append(dst, ";2;"...)
+	dst = strconv.AppendUint(dst, uint64(r>>8), 10)
+	dst = append(dst, ';')
+	dst = strconv.AppendUint(dst, uint64(g>>8), 10)
+	dst = append(dst, ';')
+	dst = strconv.AppendUint(dst, uint64(b>>8), 10)
+	return append(dst, 'm')
+}
+
+func (p *Preview) AppendFooter(dst []byte) []byte {
+	return append(dst, "\x1b[0m\r\n"...)
+}
+
+// ReadClicks reads keys from a terminal in raw mode. The arrow keys, h and l
+// move the selection, 1 to 9 click the selected block with that button, and
+// enter or space left click it. q or ^C stop the preview.
+func (p *Preview) ReadClicks(in io.Reader, fn func(ClickEvent)) error {
+	br := bufio.NewReader(in)
+	for {
+		key, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		move := 0
+		button := 0
+		switch key {
+		case 'q', 0x03, 0x04:
+			return io.EOF
+		case 'h':
+			move = -1
+		case 'l', '\t':
+			move = 1
+		case '\r', '\n', ' ':
+			button = ButtonLeft
+		case 0x1b:
+			// arrow keys are sent as ESC [ C and ESC [ D
+			if next, _ := br.Peek(2); len(next) == 2 && next[0] == '[' {
+				br.Discard(2)
+				switch next[1] {
+				case 'C':
+					move = 1
+				case 'D':
+					move = -1
+				}
+			}
+		default:
+			if key >= '1' && key <= '9' {
+				button = int(key - '0')
+			}
+		}
+
+		if move != 0 {
+			p.move(move)
+		}
+		if button != 0 {
+			p.mu.Lock()
+			ev := ClickEvent{Button: button}
+			if p.selected < len(p.blocks) {
+				ev.Name = p.blocks[p.selected].Name
+				ev.Instance = p.blocks[p.selected].Instance
+			}
+			p.mu.Unlock()
+			fn(ev)
+		}
+	}
+}
+
+// move changes the selection to the next visible block in the direction of
+// delta, and redraws the line
+func (p *Preview) move(delta int) {
+	p.mu.Lock()
+	n := len(p.blocks)
+	if n == 0 {
+		p.mu.Unlock()
+		return
+	}
+	for i := 0; i < n; i++ {
+		p.selected = (p.selected + delta + n) % n
+		if p.blocks[p.selected].FullText != "" {
+			break
+		}
+	}
+	s := p.blocks[p.selected]
+	p.selectedID = blockID{s.Name, s.Instance}
+	blocks := append([]StatusBlock(nil), p.blocks...)
+	out := p.out
+	p.mu.Unlock()
+
+	if out != nil {
+		out.Write(p.AppendFrame(nil, blocks))
+	}
+}
+
+// lockedWriter serializes writes from the render goroutine and the Preview's
+// own redraws
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(b)
+}
+
+// Preview runs the bar in the terminal on stdin and stdout until q is pressed
+func (c Config) Preview(ctx context.Context) error {
+	fd := int(os.Stdin.Fd())
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("preview: stdin is not a terminal: %v", err)
+	}
+	raw := *termios
+	raw.Lflag &^= unix.ICANON | unix.ECHO | unix.ISIG
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	err = unix.IoctlSetTermios(fd, unix.TCSETS, &raw)
+	if err != nil {
+		return fmt.Errorf("preview: unable to set raw mode: %v", err)
+	}
+	defer unix.IoctlSetTermios(fd, unix.TCSETS, termios)
+
+	out := &lockedWriter{w: os.Stdout}
+	out.Write([]byte("\x1b[?25l"))
+	defer out.Write([]byte("\x1b[?25h\r\n"))
+
+	// exec'ing a new binary would leave the terminal in raw mode
+	c.DontWatchBinary = true
+	c.Renderer = &Preview{out: out}
+	return c.Run(ctx, os.Stdin, out)
+}
@@ -0,0 +1,343 @@
+package my3status
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+func init() {
+	RegisterWidget("cpu", newCPUFromConfig)
+	RegisterWidget("memory", newMemoryFromConfig)
+	RegisterWidget("temperature", newTemperatureFromConfig)
+	RegisterWidget("time", newTimeFromConfig)
+	RegisterWidget("apcupsd", newAPCUPSDFromConfig)
+	RegisterWidget("nvidia", newNvidiaFromConfig)
+	RegisterWidget("text", newTextFromConfig)
+	RegisterWidget("edit", newEditFromConfig)
+	RegisterWidget("switcher", newSwitcherFromConfig)
+	RegisterWidget("group", newGroupFromConfig)
+	RegisterWidget("message", newMessageFromConfig)
+	RegisterWidget("exec", newExecFromConfig)
+	RegisterWidget("plugin", newPluginFromConfig)
+}
+
+// {"type": "cpu", "colors": "htop", "width": 24, "short_interval": "5s",
+// "show_1": true, "show_5": false, "show_15": true}
+//
+// colors may also be an object of pango span attributes, such as
+// {"user": "foreground=\"#00FF00\""}
+func newCPUFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Colors        json.RawMessage `json:"colors"`
+		Width         int             `json:"width"`
+		ShortInterval duration        `json:"short_interval"`
+		Show1         bool            `json:"show_1"`
+		Show5         bool            `json:"show_5"`
+		Show15        bool            `json:"show_15"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	cpu := &CPU{
+		Width:         opts.Width,
+		ShortInterval: time.Duration(opts.ShortInterval),
+		Show1:         opts.Show1,
+		Show5:         opts.Show5,
+		Show15:        opts.Show15,
+	}
+	if len(opts.Colors) > 0 {
+		var name string
+		if json.Unmarshal(opts.Colors, &name) == nil {
+			if name != "htop" {
+				return nil, fmt.Errorf("unknown colors %q", name)
+			}
+			cpu.Colors = HTOPAdvancedCPUColors()
+		} else {
+			cpu.Colors = &CPUColors{}
+			err = json.Unmarshal(opts.Colors, cpu.Colors)
+			if err != nil {
+				return nil, fmt.Errorf("colors: %v", err)
+			}
+		}
+	}
+	return cpu, nil
+}
+
+// {"type": "memory"}
+func newMemoryFromConfig(c *WidgetConfig) (Widget, error) {
+	return &Memory{}, nil
+}
+
+// {"type": "temperature", "path": "/sys/class/hwmon/hwmon0/temp1_input",
+// "divisor": 1000, "format": "%.0f°C"}
+func newTemperatureFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Path    string  `json:"path"`
+		Divisor float64 `json:"divisor"`
+		Format  string  `json:"format"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Path == "" {
+		return nil, fmt.Errorf("missing \"path\"")
+	}
+	return &Temperature{
+		Path:    opts.Path,
+		Divisor: opts.Divisor,
+		Format:  opts.Format,
+	}, nil
+}
+
+// {"type": "time", "format": "Mon 15:04", "short_format": "15:04",
+// "location": "UTC"}
+func newTimeFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Format      string `json:"format"`
+		ShortFormat string `json:"short_format"`
+		Location    string `json:"location"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Format == "" {
+		opts.Format = "Mon 15:04:05"
+	}
+	t := &Time{
+		Format:       opts.Format,
+		ShortFormat:  opts.ShortFormat,
+		LocationName: opts.Location,
+	}
+	if opts.Location != "" {
+		t.Location, err = time.LoadLocation(opts.Location)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// {"type": "apcupsd", "host": "localhost:3551", "interval": "3s"}
+func newAPCUPSDFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Host     string   `json:"host"`
+		Interval duration `json:"interval"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Host == "" {
+		opts.Host = "localhost:3551"
+	}
+	return &APCUPSDStatus{
+		Host:     opts.Host,
+		Interval: time.Duration(opts.Interval),
+	}, nil
+}
+
+// {"type": "nvidia", "format": "%s°G"}
+func newNvidiaFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Format string `json:"format"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	return &NvidiaTemperature{
+		Format: opts.Format,
+	}, nil
+}
+
+// {"type": "text", "full_text": "hello", "short_text": "hi"}
+//
+// An empty text widget can be used to make the previous widget's separator
+// apply
+func newTextFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		FullText  string `json:"full_text"`
+		ShortText string `json:"short_text"`
+		Tooltip   string `json:"tooltip"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	return StatusBlock{
+		FullText:  opts.FullText,
+		ShortText: opts.ShortText,
+		Tooltip:   opts.Tooltip,
+	}, nil
+}
+
+// {"type": "edit", "widget": {...}, "separator_block_width": 8}
+//
+// Every widget accepts the override keys, so edit is only needed to make them
+// explicit
+func newEditFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Widget *WidgetConfig `json:"widget"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Widget == nil {
+		return nil, fmt.Errorf("missing \"widget\"")
+	}
+	return opts.Widget.Build()
+}
+
+// decodeWidgets builds the "widgets" array of c
+func decodeWidgets(c *WidgetConfig) ([]Widget, error) {
+	opts := struct {
+		Widgets []*WidgetConfig `json:"widgets"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Widgets) == 0 {
+		return nil, fmt.Errorf("missing \"widgets\"")
+	}
+	return BuildAll(opts.Widgets)
+}
+
+// {"type": "switcher", "widgets": [...]}
+func newSwitcherFromConfig(c *WidgetConfig) (Widget, error) {
+	widgets, err := decodeWidgets(c)
+	if err != nil {
+		return nil, err
+	}
+	return &StatefulSwitcher{
+		Widgets: Switcher(widgets),
+	}, nil
+}
+
+// {"type": "group", "widgets": [...]}
+func newGroupFromConfig(c *WidgetConfig) (Widget, error) {
+	widgets, err := decodeWidgets(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Group{
+		Widgets: widgets,
+	}, nil
+}
+
+// {"type": "message", "name": "deploy", "fifo": "/run/user/1000/deploy",
+// "ttl": "5m"}
+func newMessageFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Name string   `json:"name"`
+		FIFO string   `json:"fifo"`
+		TTL  duration `json:"ttl"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		Name: opts.Name,
+		FIFO: opts.FIFO,
+		TTL:  time.Duration(opts.TTL),
+	}, nil
+}
+
+// {"type": "exec", "command": "~/.config/i3blocks/volume", "interval": "5s",
+// "format": "json", "timeout": "10s", "signal": 1, "name": "volume",
+// "instance": "Master", "label": "VOL ", "env": {"STEP": "5%"},
+// "defaults": {"color": "#FF0000", "urgent": true}}
+//
+// interval may also be "once", "repeat" or "persist". defaults takes the same
+// keys as the overrides of any widget, other than name and instance, but the
+// command's output takes precedence over them
+func newExecFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Command  string            `json:"command"`
+		Interval json.RawMessage   `json:"interval"`
+		Format   string            `json:"format"`
+		Timeout  duration          `json:"timeout"`
+		Signal   int               `json:"signal"`
+		Name     string            `json:"name"`
+		Instance string            `json:"instance"`
+		Label    string            `json:"label"`
+		Env      map[string]string `json:"env"`
+		Defaults blockOverrides    `json:"defaults"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Command == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	e := &Exec{
+		Command:  opts.Command,
+		Timeout:  time.Duration(opts.Timeout),
+		Signal:   opts.Signal,
+		Name:     opts.Name,
+		Instance: opts.Instance,
+		Label:    opts.Label,
+	}
+	opts.Defaults.apply(&e.Defaults)
+	for k, v := range opts.Env {
+		e.Env = append(e.Env, k+"="+v)
+	}
+	sort.Strings(e.Env)
+	switch opts.Format {
+	case "", "text":
+	case "json":
+		e.JSON = true
+	default:
+		return nil, fmt.Errorf("unknown format %q", opts.Format)
+	}
+	if len(opts.Interval) > 0 {
+		var mode string
+		if json.Unmarshal(opts.Interval, &mode) == nil && ExecMode(mode) != ExecInterval {
+			switch ExecMode(mode) {
+			case ExecOnce, ExecRepeat, ExecPersist:
+				e.Mode = ExecMode(mode)
+				return e, nil
+			}
+		}
+		var d duration
+		err = json.Unmarshal(opts.Interval, &d)
+		if err != nil {
+			return nil, fmt.Errorf("interval: %v", err)
+		}
+		e.Interval = time.Duration(d)
+	}
+	return e, nil
+}
+
+// {"type": "plugin", "command": ["python3", "/path/to/plugin.py"],
+// "timeout": "30s", "stale_color": "#7F7F7F"}
+func newPluginFromConfig(c *WidgetConfig) (Widget, error) {
+	opts := struct {
+		Command    []string   `json:"command"`
+		Timeout    duration   `json:"timeout"`
+		StaleColor *jsonColor `json:"stale_color"`
+	}{}
+	err := c.Decode(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Command) == 0 {
+		return nil, fmt.Errorf("command is required")
+	}
+	p := &PluginWidget{
+		Command: opts.Command,
+		Timeout: time.Duration(opts.Timeout),
+	}
+	if opts.StaleColor != nil {
+		p.StaleColor = opts.StaleColor.Color
+	}
+	return p, nil
+}
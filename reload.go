@@ -10,6 +10,7 @@ import (
 
 var callbackLock = &sync.Mutex{}
 var restartCallbacks = []func(){}
+var exitCallbacks = []func(){}
 
 // Restart causes the running application to be restarted in place
 // This happens immediately after calling all callbacks added with
@@ -20,15 +21,19 @@ func Restart() {
 	if err != nil {
 		panic(fmt.Errorf("Restart: unable to get executable: %v", err))
 	}
-	for _, cb := range restartCallbacks {
-		cb()
-	}
+	runCallbacks(&restartCallbacks)
 	err = unix.Exec(binary, os.Args, os.Environ())
 	if err != nil {
 		panic(fmt.Errorf("Restart: unable to execve new binary: %v", err))
 	}
 }
 
+// executable reports whether path is a regular file that can be executed
+func executable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
 // BeforeRestart adds callback to a list of methods to be called before
 // the application is restarted
 func BeforeRestart(callback func()) {
@@ -37,6 +42,25 @@ func BeforeRestart(callback func()) {
 	restartCallbacks = append(restartCallbacks, callback)
 }
 
+// BeforeExit adds callback to a list of methods to be called before Loop
+// returns, such as when it receives SIGTERM
+func BeforeExit(callback func()) {
+	callbackLock.Lock()
+	defer callbackLock.Unlock()
+	exitCallbacks = append(exitCallbacks, callback)
+}
+
+// runCallbacks calls each of a list of callbacks. callbackLock is not held
+// while they run, so they may add more callbacks
+func runCallbacks(list *[]func()) {
+	callbackLock.Lock()
+	cbs := append([]func(){}, *list...)
+	callbackLock.Unlock()
+	for _, cb := range cbs {
+		cb()
+	}
+}
+
 // CloseFileBeforeRestart marks the file to be closed in case of a Restart
 // This is logically similar to `BeforeRestart(func(){file.Close()}), though
 // more efficient
@@ -0,0 +1,38 @@
+package my3status
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecutable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "my3status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	binary := filepath.Join(dir, "binary")
+	if executable(binary) {
+		t.Error("a missing file is executable")
+	}
+	err = ioutil.WriteFile(binary, []byte("#!/bin/sh\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executable(binary) {
+		t.Error("a file without an exec bit is executable")
+	}
+	err = os.Chmod(binary, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !executable(binary) {
+		t.Error("an executable file is not executable")
+	}
+	if executable(dir) {
+		t.Error("a directory is executable")
+	}
+}
@@ -0,0 +1,173 @@
+package my3status
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// A Renderer converts StatusBlocks into the format understood by a status bar,
+// and reads ClickEvents back from it. Blocks passed to a Renderer always have
+// their Name, Instance and Separator filled in.
+type Renderer interface {
+	// AppendHeader appends anything that must be written before the first
+	// frame. It is not called again after a Restart.
+	AppendHeader(dst []byte, c Config) []byte
+
+	// AppendFrame appends a single frame of blocks
+	AppendFrame(dst []byte, blocks []StatusBlock) []byte
+
+	// AppendFooter appends anything that must be written when Run returns
+	// because it's context was cancelled
+	AppendFooter(dst []byte) []byte
+
+	// ReadClicks reads ClickEvents from in, calling fn for each one, until in
+	// returns an error. Renderers for bars which cannot report clicks may
+	// return nil immediately
+	ReadClicks(in io.Reader, fn func(ClickEvent)) error
+}
+
+// I3Bar renders the i3bar protocol. This is also used by swaybar. It is the
+// default Renderer
+type I3Bar struct{}
+
+// i3barHeader is the first object sent to i3bar
+type i3barHeader struct {
+	Version     int            `json:"version"`
+	ClickEvents bool           `json:"click_events"`
+	StopSignal  syscall.Signal `json:"stop_signal,omitempty"`
+	ContSignal  syscall.Signal `json:"cont_signal,omitempty"`
+}
+
+func (I3Bar) AppendHeader(dst []byte, c Config) []byte {
+	header, _ := json.Marshal(i3barHeader{
+		Version:     1,
+		ClickEvents: true,
+		StopSignal:  c.StopSignal,
+		ContSignal:  c.ContSignal,
+	})
+	dst = append(dst, header...)
+	return append(dst, '[')
+}
+
+func (I3Bar) AppendFrame(dst []byte, blocks []StatusBlock) []byte {
+	dst = append(dst, '[')
+	for i, s := range blocks {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendBlock(dst, s)
+	}
+	return append(dst, "],\n"...)
+}
+
+func (I3Bar) AppendFooter(dst []byte) []byte {
+	return append(dst, "]\n"...)
+}
+
+// ReadClicks reads click events from i3bar until in returns an error. i3bar
+// writes each event on it's own line, inside of an infinite array, so the
+// array punctuation is stripped from each line. This lets a Restarted process
+// pick up the stream in the middle.
+func (I3Bar) ReadClicks(in io.Reader, fn func(ClickEvent)) error {
+	return readLines(in, func(line []byte) error {
+		line = bytes.TrimPrefix(line, []byte("["))
+		line = bytes.TrimSpace(line)
+		line = bytes.TrimPrefix(line, []byte(","))
+		line = bytes.TrimSuffix(line, []byte(","))
+		if len(line) == 0 {
+			return nil
+		}
+		ev := ClickEvent{}
+		err := json.Unmarshal(line, &ev)
+		if err != nil {
+			return err
+		}
+		fn(ev)
+		return nil
+	})
+}
+
+// readLines calls fn with each non-empty line of in, with surrounding
+// whitespace removed, until in or fn returns an error
+func readLines(in io.Reader, fn func(line []byte) error) error {
+	br := bufio.NewReader(in)
+	for {
+		line, err := br.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			ferr := fn(line)
+			if ferr != nil {
+				return ferr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// clickActionPrefix starts every line written back to my3status by bars which
+// can only run commands when clicked
+const clickActionPrefix = "my3status"
+
+// clickAction returns the line that should be sent back to ReadClicks when
+// the block is clicked with button. The name and instance are query escaped,
+// so the line can be embedded in most bars' markup without further escaping
+func clickAction(button int, s StatusBlock) string {
+	return clickActionPrefix + " " + strconv.Itoa(button) + " " +
+		url.QueryEscape(s.Name) + " " + url.QueryEscape(s.Instance)
+}
+
+// parseClickAction parses a line created by clickAction
+func parseClickAction(line string) (ClickEvent, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[0] != clickActionPrefix {
+		return ClickEvent{}, false
+	}
+	button, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ClickEvent{}, false
+	}
+	name, err := url.QueryUnescape(fields[2])
+	if err != nil {
+		return ClickEvent{}, false
+	}
+	instance, err := url.QueryUnescape(fields[3])
+	if err != nil {
+		return ClickEvent{}, false
+	}
+	return ClickEvent{
+		Name:     name,
+		Instance: instance,
+		Button:   button,
+	}, true
+}
+
+// readClickActions reads lines created by clickAction from in, ignoring any
+// other lines
+func readClickActions(in io.Reader, fn func(ClickEvent)) error {
+	return readLines(in, func(line []byte) error {
+		ev, ok := parseClickAction(string(line))
+		if ok {
+			fn(ev)
+		}
+		return nil
+	})
+}
+
+// separatorGap returns the number of pixels to leave on either side of the
+// separator after s, and whether a separator line should be drawn
+func separatorGap(s StatusBlock) (left, right int, draw bool) {
+	width := 9
+	if s.Separator.Width != nil {
+		width = *s.Separator.Width
+	}
+	draw = s.Separator.Hide == nil || !*s.Separator.Hide
+	return width / 2, width - width/2, draw
+}
@@ -0,0 +1,159 @@
+package my3status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// A StatefulWidget can keep it's state across a Restart. When Loop restarts
+// the process it calls SaveState, and in the new process it calls
+// RestoreState with the saved data before the first call to Status. State is
+// matched to Widgets by their position and type, so it is dropped for Widgets
+// which were moved or replaced by the new binary
+type StatefulWidget interface {
+	Widget
+	SaveState() ([]byte, error)
+	RestoreState([]byte) error
+}
+
+// saveState returns the state of w, or nil if it is not a StatefulWidget
+func saveState(w Widget) ([]byte, error) {
+	if sw, ok := w.(StatefulWidget); ok {
+		return sw.SaveState()
+	}
+	return nil, nil
+}
+
+// restoreState passes state to w if it is a StatefulWidget
+func restoreState(w Widget, state []byte) error {
+	if sw, ok := w.(StatefulWidget); ok && state != nil {
+		return sw.RestoreState(state)
+	}
+	return nil
+}
+
+// saveStates saves the state of every Widget in ws
+func saveStates(ws []Widget) ([][]byte, error) {
+	states := make([][]byte, len(ws))
+	for i, w := range ws {
+		state, err := saveState(w)
+		if err != nil {
+			return nil, err
+		}
+		states[i] = state
+	}
+	return states, nil
+}
+
+// restoreStates restores states saved by saveStates. If the number of Widgets
+// changed the states are discarded
+func restoreStates(ws []Widget, states [][]byte) error {
+	if len(ws) != len(states) {
+		return nil
+	}
+	for i, w := range ws {
+		err := restoreState(w, states[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const envState = "MY3STATUS_STATE"
+
+// handoff is what Loop carries across a Restart
+type handoff struct {
+	// continued is set if the header has already been written
+	continued bool
+
+	// states are the saved states of the Widgets, by stateKey
+	states map[string][]byte
+}
+
+// stateKey identifies a Widget across a Restart
+func stateKey(index int, ws *widgetState) string {
+	return strconv.Itoa(index) + ":" + ws.name
+}
+
+// readHandoff reads the handoff left by the process that Restarted into this
+// one, and prepares the environment for the next Restart
+func readHandoff() *handoff {
+	h := &handoff{
+		continued: os.Getenv(envContinue) == envValueYes,
+	}
+	os.Setenv(envContinue, envValueYes)
+
+	if path := os.Getenv(envState); path != "" {
+		os.Unsetenv(envState)
+		data, err := ioutil.ReadFile(path)
+		os.Remove(path)
+		if err == nil {
+			err = json.Unmarshal(data, &h.states)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to read saved state: %v\n", err)
+		}
+	}
+	return h
+}
+
+// restore passes each Widget it's state from the previous process
+func (h *handoff) restore(states []*widgetState) {
+	for index, ws := range states {
+		state, ok := h.states[stateKey(index, ws)]
+		if !ok {
+			continue
+		}
+		err := restoreState(ws.widget, state)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to restore %v: %v\n", ws.name, err)
+		}
+	}
+	h.states = nil
+}
+
+// save writes the state of every StatefulWidget to a temporary file, which is
+// passed to the next process in the environment. Widgets which are still in
+// Status after timeout are skipped
+func (h *handoff) save(states []*widgetState, timeout time.Duration) error {
+	waitIdle(states, timeout)
+
+	saved := map[string][]byte{}
+	for index, ws := range states {
+		if _, ok := ws.widget.(StatefulWidget); !ok || ws.pending {
+			continue
+		}
+		state, err := saveState(ws.widget)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to save %v: %v\n", ws.name, err)
+			continue
+		}
+		saved[stateKey(index, ws)] = state
+	}
+	if len(saved) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile("", "my3status-state")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Setenv(envState, f.Name())
+}
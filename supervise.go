@@ -0,0 +1,55 @@
+package my3status
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// recoverPanic recovers a panic in a function which returns err, converting it
+// to an error. The panic and it's stack are logged to stderr. It must be
+// deferred directly
+func recoverPanic(name string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "panic in %v: %v\n%s", name, r, debug.Stack())
+	*err = fmt.Errorf("panic: %v", r)
+}
+
+// runRecovered is run, but a panic on the render goroutine is returned as an
+// error
+func (c Config) runRecovered(ctx context.Context, in io.Reader, w io.Writer, h *handoff) (err error) {
+	defer recoverPanic("Loop", &err)
+	return c.run(ctx, in, w, h)
+}
+
+const envBackoff = "MY3STATUS_BACKOFF"
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// superviseRestart Restarts the process after it failed with err. The delay
+// doubles for each consecutive failure, and is reset if the process ran for
+// longer than the maximum delay
+func superviseRestart(err error, started time.Time) {
+	backoff, perr := time.ParseDuration(os.Getenv(envBackoff))
+	if perr != nil || backoff < minBackoff || time.Since(started) > maxBackoff {
+		backoff = minBackoff
+	}
+	fmt.Fprintf(os.Stderr, "my3status: %v, restarting in %v\n", err, backoff)
+	time.Sleep(backoff)
+
+	next := backoff * 2
+	if next > maxBackoff {
+		next = maxBackoff
+	}
+	os.Setenv(envBackoff, next.String())
+	Restart()
+}
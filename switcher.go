@@ -1,5 +1,7 @@
 package my3status
 
+import "encoding/json"
+
 // Switcher switches between it's constituent widgets when clicked on
 type Switcher []Widget
 
@@ -13,3 +15,104 @@ func (s Switcher) Click(c ClickEvent) bool {
 	s[len(s)-1] = t
 	return true
 }
+
+func (s Switcher) SetUpdater(u Updater) {
+	for _, w := range s {
+		setUpdater(w, u)
+	}
+}
+
+func (s Switcher) Pause() {
+	for _, w := range s {
+		pause(w)
+	}
+}
+
+func (s Switcher) Resume() {
+	for _, w := range s {
+		resume(w)
+	}
+}
+
+func (s Switcher) Close() error {
+	return closeWidgets(s)
+}
+
+func (s Switcher) WatchedFiles() []string {
+	var files []string
+	for _, w := range s {
+		files = appendWatchedFiles(files, w)
+	}
+	return files
+}
+
+// StatefulSwitcher is a Switcher which keeps the widget it is showing, and the
+// state of every widget, across Restart
+type StatefulSwitcher struct {
+	Widgets Switcher
+
+	// offset is how many times Widgets has been rotated
+	offset int
+}
+
+func (s *StatefulSwitcher) Status() (StatusBlock, error) {
+	return s.Widgets.Status()
+}
+
+func (s *StatefulSwitcher) Click(c ClickEvent) bool {
+	s.offset = (s.offset + 1) % len(s.Widgets)
+	return s.Widgets.Click(c)
+}
+
+func (s *StatefulSwitcher) SetUpdater(u Updater) {
+	s.Widgets.SetUpdater(u)
+}
+
+func (s *StatefulSwitcher) Pause() {
+	s.Widgets.Pause()
+}
+
+func (s *StatefulSwitcher) Resume() {
+	s.Widgets.Resume()
+}
+
+func (s *StatefulSwitcher) Close() error {
+	return s.Widgets.Close()
+}
+
+func (s *StatefulSwitcher) WatchedFiles() []string {
+	return s.Widgets.WatchedFiles()
+}
+
+type switcherState struct {
+	Offset  int
+	Widgets [][]byte
+}
+
+// SaveState saves the rotation, and the state of every widget in it's rotated
+// order
+func (s *StatefulSwitcher) SaveState() ([]byte, error) {
+	states, err := saveStates(s.Widgets)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(switcherState{
+		Offset:  s.offset,
+		Widgets: states,
+	})
+}
+
+func (s *StatefulSwitcher) RestoreState(data []byte) error {
+	state := switcherState{}
+	err := json.Unmarshal(data, &state)
+	if err != nil {
+		return err
+	}
+	if len(state.Widgets) != len(s.Widgets) || state.Offset < 0 || state.Offset >= len(s.Widgets) {
+		return nil
+	}
+	for s.offset != state.Offset {
+		s.Click(ClickEvent{})
+	}
+	return restoreStates(s.Widgets, state.Widgets)
+}
@@ -0,0 +1,36 @@
+package my3status
+
+import "testing"
+
+func TestStatefulSwitcherRestoreState(t *testing.T) {
+	newSwitcher := func() *StatefulSwitcher {
+		return &StatefulSwitcher{
+			Widgets: Switcher{
+				StatusBlock{FullText: "a"},
+				StatusBlock{FullText: "b"},
+				StatusBlock{FullText: "c"},
+			},
+		}
+	}
+
+	s := newSwitcher()
+	s.Click(ClickEvent{})
+	s.Click(ClickEvent{})
+	data, err := s.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newSwitcher()
+	err = restored.RestoreState(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"c", "a", "b"} {
+		b, _ := restored.Status()
+		if b.FullText != expected {
+			t.Errorf("got %q, expected %q", b.FullText, expected)
+		}
+		restored.Click(ClickEvent{})
+	}
+}
@@ -14,22 +14,39 @@ type Temperature struct {
 	Divisor float64
 	Format  string
 	file    ProcFile
+
+	// path is the file Path matched, which is kept until it cannot be read
+	path string
+
+	// last is the contents the text was last formatted from
+	last []byte
+	text string
 }
 
 func (t *Temperature) Status() (StatusBlock, error) {
-	paths, err := filepath.Glob(t.Path)
-	if err != nil {
-		return StatusBlock{}, fmt.Errorf("Temp: %v", err)
+	if t.path == "" {
+		paths, err := filepath.Glob(t.Path)
+		if err != nil {
+			return StatusBlock{}, fmt.Errorf("Temp: %v", err)
+		}
+		if len(paths) != 1 {
+			return StatusBlock{}, fmt.Errorf("Temp: %q does not match one file: %v", t.Path, paths)
+		}
+		t.path = paths[0]
 	}
-	if len(paths) != 1 {
-		return StatusBlock{}, fmt.Errorf("Temp: %q does not match one file: %v", t.Path, paths)
-	}
-	contents, err := t.file.Read(paths[0])
+	contents, err := t.file.Read(t.path)
 	if err != nil {
+		// hwmon devices may be renumbered, so match Path again next time
+		t.path = ""
 		return StatusBlock{}, err
 	}
 
 	contents = bytes.TrimRight(contents, "\n")
+	if t.last != nil && bytes.Equal(contents, t.last) {
+		return StatusBlock{
+			FullText: t.text,
+		}, nil
+	}
 
 	val, err := strconv.Atoi(string(contents))
 	if err != nil {
@@ -46,7 +63,9 @@ func (t *Temperature) Status() (StatusBlock, error) {
 		format = "%.0f°C"
 	}
 
+	t.last = append(t.last[:0], contents...)
+	t.text = fmt.Sprintf(format, value)
 	return StatusBlock{
-		FullText: fmt.Sprintf(format, value),
+		FullText: t.text,
 	}, nil
 }
@@ -13,6 +13,8 @@ type Time struct {
 	// Name of the timezone to use
 	LocationName string
 	Location     *time.Location
+
+	text, shortText textBuffer
 }
 
 func (t *Time) Status() (StatusBlock, error) {
@@ -31,9 +33,11 @@ func (t *Time) Status() (StatusBlock, error) {
 	}
 
 	block := StatusBlock{}
-	block.FullText = now.Format(t.Format)
+	t.text.buf = now.AppendFormat(t.text.buf[:0], t.Format)
+	block.FullText = t.text.String()
 	if t.ShortFormat != "" {
-		block.ShortText = now.Format(t.ShortFormat)
+		t.shortText.buf = now.AppendFormat(t.shortText.buf[:0], t.ShortFormat)
+		block.ShortText = t.shortText.String()
 	}
 	return block, nil
 }
@@ -0,0 +1,210 @@
+package my3status
+
+import (
+	"image/color"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Tmux renders blocks as tmux status line markup, such as
+// "#[fg=#FF0000]text#[default]". It can be used as
+//
+//	set -g status-right "#(my3status -tmux)"
+//
+// tmux keeps the last line written by a command that does not exit, or
+// my3status can be run with -once to print a single frame
+type Tmux struct {
+	// Width is the number of columns available. If the blocks' FullText does
+	// not fit, their ShortText is used instead. If unset the FullText is
+	// always used
+	Width int
+
+	// Separator is drawn between blocks which do not hide their separator. If
+	// unset " | " is used
+	Separator string
+
+	// The colors used for urgent blocks. If both are unset the block's colors
+	// are reversed instead
+	UrgentColor      color.Color
+	UrgentBackground color.Color
+}
+
+func (t Tmux) AppendHeader(dst []byte, c Config) []byte {
+	return dst
+}
+
+func (t Tmux) AppendFrame(dst []byte, blocks []StatusBlock) []byte {
+	separator := t.Separator
+	if separator == "" {
+		separator = " | "
+	}
+
+	short := t.Width > 0 && t.width(blocks, separator, false) > t.Width
+
+	var prev *StatusBlock
+	for i, s := range blocks {
+		if s.FullText == "" {
+			continue
+		}
+		if prev != nil {
+			dst = append(dst, tmuxSeparator(*prev, separator)...)
+		}
+		prev = &blocks[i]
+		dst = t.appendBlock(dst, s, short)
+	}
+	return append(dst, '\n')
+}
+
+// width returns the number of columns the blocks take up
+func (t Tmux) width(blocks []StatusBlock, separator string, short bool) int {
+	width := 0
+	var prev *StatusBlock
+	for i, s := range blocks {
+		if s.FullText == "" {
+			continue
+		}
+		if prev != nil {
+			width += utf8.RuneCountInString(tmuxSeparator(*prev, separator))
+		}
+		prev = &blocks[i]
+		width += utf8.RuneCountInString(plainText(tmuxText(s, short), s.Markup))
+	}
+	return width
+}
+
+// tmuxText returns the ShortText of s if short is set and it has one, or the
+// FullText otherwise
+func tmuxText(s StatusBlock, short bool) string {
+	if short && s.ShortText != "" {
+		return s.ShortText
+	}
+	return s.FullText
+}
+
+// tmuxSeparator returns the text drawn after s
+func tmuxSeparator(s StatusBlock, separator string) string {
+	left, right, draw := separatorGap(s)
+	if draw {
+		return separator
+	}
+	if left+right > 0 {
+		return " "
+	}
+	return ""
+}
+
+func (t Tmux) appendBlock(dst []byte, s StatusBlock, short bool) []byte {
+	style := tmuxStyle{
+		fg: s.Color,
+		bg: s.Background,
+	}
+	if s.Urgent {
+		if t.UrgentColor == nil && t.UrgentBackground == nil {
+			style.reverse = true
+		} else {
+			style.fg, style.bg = t.UrgentColor, t.UrgentBackground
+		}
+	}
+
+	text := tmuxText(s, short)
+	if s.Markup != MarkupPango {
+		return style.appendText(dst, text)
+	}
+
+	for _, span := range parsePango(text) {
+		spanStyle := style
+		if span.Foreground != nil {
+			spanStyle.fg = span.Foreground
+		}
+		if span.Background != nil {
+			spanStyle.bg = span.Background
+		}
+		spanStyle.pangoStyle = span.pangoStyle
+		dst = spanStyle.appendText(dst, span.Text)
+	}
+	return dst
+}
+
+type tmuxStyle struct {
+	fg, bg  color.Color
+	reverse bool
+	pangoStyle
+}
+
+// appendText appends text in this style, followed by a reset to the default
+// style if needed
+func (s tmuxStyle) appendText(dst []byte, text string) []byte {
+	start := len(dst)
+	dst = s.append(dst)
+	styled := len(dst) != start
+	dst = appendTmuxText(dst, text)
+	if styled {
+		dst = append(dst, "#[default]"...)
+	}
+	return dst
+}
+
+// append appends a #[...] style directive, if the style is not the default
+func (s tmuxStyle) append(dst []byte) []byte {
+	attrs := make([]string, 0, 8)
+	if s.fg != nil {
+		attrs = append(attrs, "fg="+string(appendHexColor(nil, opaque(s.fg))))
+	}
+	if s.bg != nil {
+		attrs = append(attrs, "bg="+string(appendHexColor(nil, opaque(s.bg))))
+	}
+	if s.reverse {
+		attrs = append(attrs, "reverse")
+	}
+	if s.Underline {
+		attrs = append(attrs, "underscore")
+	}
+	if s.UnderlineColor != nil {
+		// tmux 3.0 and newer can color underlines
+		attrs = append(attrs, "us="+string(appendHexColor(nil, opaque(s.UnderlineColor))))
+		if !s.Underline {
+			attrs = append(attrs, "underscore")
+		}
+	}
+	if s.Bold {
+		attrs = append(attrs, "bold")
+	}
+	if s.Italic {
+		attrs = append(attrs, "italics")
+	}
+	if s.Strikethrough {
+		attrs = append(attrs, "strikethrough")
+	}
+	if len(attrs) == 0 {
+		return dst
+	}
+	dst = append(dst, "#["...)
+	dst = append(dst, strings.Join(attrs, ",")...)
+	return append(dst, ']')
+}
+
+// opaque drops the alpha channel of c, for formats which do not support it
+func opaque(c color.Color) color.Color {
+	r, g, b, a := c.RGBA()
+	if a != 0 && a != 0xFFFF {
+		r = r * 0xFFFF / a
+		g = g * 0xFFFF / a
+		b = b * 0xFFFF / a
+	}
+	return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: 0xFFFF}
+}
+
+func appendTmuxText(dst []byte, text string) []byte {
+	text = strings.Replace(text, "\n", " ", -1)
+	return append(dst, strings.Replace(text, "#", "##", -1)...)
+}
+
+func (t Tmux) AppendFooter(dst []byte) []byte {
+	return dst
+}
+
+// ReadClicks returns immediately, as tmux does not report clicks
+func (t Tmux) ReadClicks(in io.Reader, fn func(ClickEvent)) error {
+	return nil
+}
@@ -1,6 +1,9 @@
 package my3status
 
-import "image/color"
+import (
+	"image/color"
+	"io"
+)
 
 // Alignment controls the direction to float the text
 // AlignLeft is the default
@@ -53,6 +56,13 @@ type StatusBlock struct {
 	// Overrides the border color for this particular block.
 	Border color.Color
 
+	// The width (in pixels) of each side of the border. i3bar draws a one
+	// pixel border on every side by default.
+	BorderTop    *int
+	BorderRight  *int
+	BorderBottom *int
+	BorderLeft   *int
+
 	// The minimum width (in pixels) of the block. If the content of the FullText
 	// field take less space than the specified min_width, the block will be
 	// padded to the left and/or the right side, according to the align key. This
@@ -64,6 +74,10 @@ type StatusBlock struct {
 	// particular size.
 	MinWidth int
 
+	// MinWidthText is the string form of MinWidth, and is used instead of it if
+	// set.
+	MinWidthText string
+
 	// Align text on the center, right or left (default) of the block, when the
 	// minimum width of the latter, specified by the min_width key, is not reached.
 	Align Alignment
@@ -81,6 +95,20 @@ type StatusBlock struct {
 	// DefaultSeparator will be used
 	Separator
 
+	// Name and Instance identify the block to i3bar, and are used to route
+	// ClickEvents back to the Widget that rendered it. If Name is unset the
+	// Widget's type is used. If Instance is unset the Widget's position in
+	// Config.Widgets is used, which changes if the Widgets are reordered. Every
+	// block should have a unique Name and Instance pair
+	Name     string
+	Instance string
+
+	// Tooltip, Alt and Percentage are not part of the i3bar protocol, and are
+	// only used by Renderers that support them, such as Waybar
+	Tooltip    string
+	Alt        string
+	Percentage *int
+
 	Extra map[string]interface{}
 }
 
@@ -103,12 +131,133 @@ type Separator struct {
 }
 
 // Widget provides StatusBlocks to the i3bar
+//
+// Loop never calls the methods of a single Widget concurrently: ClickEvents,
+// Pause and Resume are delivered between calls to Status, so Widgets do not
+// need any locking of their own unless they start goroutines. Different
+// Widgets may be called concurrently with each other.
+//
+// Widgets which hold resources, such as connections or child processes, should
+// implement io.Closer so they can be released when they are removed by a config
+// reload.
 type Widget interface {
 	Status() (StatusBlock, error)
 }
 
+// A MultiWidget is a Widget which renders as any number of StatusBlocks. Loop
+// calls StatusBlocks instead of Status on Widgets that implement it
+type MultiWidget interface {
+	Widget
+	StatusBlocks() ([]StatusBlock, error)
+}
+
+// isMultiWidget returns true if w should be rendered with StatusBlocks. An
+// Edit is only treated as a MultiWidget if the Widget it wraps is one, so
+// wrapping a Widget does not change it's default instance
+func isMultiWidget(w Widget) bool {
+	if e, ok := w.(*Edit); ok {
+		return isMultiWidget(e.Widget)
+	}
+	_, ok := w.(MultiWidget)
+	return ok
+}
+
+// appendStatusBlocks appends the blocks rendered by w to dst
+func appendStatusBlocks(dst []StatusBlock, w Widget) ([]StatusBlock, error) {
+	if isMultiWidget(w) {
+		sbs, err := w.(MultiWidget).StatusBlocks()
+		return append(dst, sbs...), err
+	}
+	s, err := w.Status()
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, s), nil
+}
+
+// An Updater requests that the bar be redrawn as soon as possible. It may be
+// called from any goroutine and never blocks. Bursts of calls are coalesced
+// into a single redraw
+type Updater func()
+
+// An UpdatingWidget is a Widget that can request a redraw outside of the normal
+// Interval, for example when a background goroutine receives new data. Loop
+// calls SetUpdater once before the first call to Status
+type UpdatingWidget interface {
+	Widget
+	SetUpdater(Updater)
+}
+
+// setUpdater passes u to w if it is an UpdatingWidget
+func setUpdater(w Widget, u Updater) {
+	if uw, ok := w.(UpdatingWidget); ok {
+		uw.SetUpdater(u)
+	}
+}
+
+// A PausableWidget is notified when the bar is hidden and shown again. Status
+// is not called between Pause and Resume. Resume may be called without a
+// preceding Pause if the process was frozen with SIGSTOP
+type PausableWidget interface {
+	Widget
+	Pause()
+	Resume()
+}
+
+// pause calls Pause on w if it is a PausableWidget
+func pause(w Widget) {
+	if pw, ok := w.(PausableWidget); ok {
+		pw.Pause()
+	}
+}
+
+// resume calls Resume on w if it is a PausableWidget
+func resume(w Widget) {
+	if pw, ok := w.(PausableWidget); ok {
+		pw.Resume()
+	}
+}
+
+// closeWidget closes w if it is an io.Closer. Loop closes Widgets that are
+// removed from the config file when it is reloaded
+func closeWidget(w Widget) error {
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// closeWidgets closes every Widget in ws, returning the first error
+func closeWidgets(ws []Widget) error {
+	var first error
+	for _, w := range ws {
+		err := closeWidget(w)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Buttons reported in ClickEvent.Button
+const (
+	ButtonLeft        = 1
+	ButtonMiddle      = 2
+	ButtonRight       = 3
+	ButtonScrollUp    = 4
+	ButtonScrollDown  = 5
+	ButtonScrollLeft  = 6
+	ButtonScrollRight = 7
+	ButtonBack        = 8
+	ButtonForward     = 9
+)
+
 // A ClickEvent is fired when the user interacts with a specific ClickableWidget
 type ClickEvent struct {
+	// The Name and Instance of the clicked block
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+
 	// X11 root window coordinates where the click occurred
 	X int `json:"x"`
 	Y int `json:"y"`
@@ -116,6 +265,10 @@ type ClickEvent struct {
 	// X11 button ID (for example 1 to 3 for left/middle/right mouse button)
 	Button int `json:"button"`
 
+	// Event is the evdev event code of the button, such as 0x110 for BTN_LEFT.
+	// It is only sent by swaybar.
+	Event int `json:"event"`
+
 	// Coordinates where the click occurred, with respect to the top left corner
 	// of the block
 	RelativeX int `json:"relative_x"`
@@ -125,9 +278,25 @@ type ClickEvent struct {
 	Width  int `json:"width"`
 	Height int `json:"height"`
 
+	// The scale of the output the bar is on. It is only sent by swaybar.
+	Scale float64 `json:"scale"`
+
+	// The name of the output the bar is on, if known.
+	Output string `json:"output"`
+
 	// An array of the modifiers active when the click occurred. The order in
 	// which modifiers are listed is not guaranteed.
 	Modifiers []string `json:"modifiers"`
+
+	// Block is the index of the clicked block in the slice returned by
+	// StatusBlocks. It is always 0 for Widgets that are not MultiWidgets
+	Block int `json:"-"`
+}
+
+// IsScroll returns true if the event was caused by a scroll wheel rather than a
+// button
+func (c ClickEvent) IsScroll() bool {
+	return c.Button >= ButtonScrollUp && c.Button <= ButtonScrollRight
 }
 
 // A ClickableWidget is a Widget that can receive ClickEvents
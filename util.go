@@ -20,14 +20,61 @@ func (w FuncWidget) Status() (StatusBlock, error) {
 type Edit struct {
 	Widget Widget
 	Func   func(*StatusBlock)
+
+	// block is edited in place, as passing a local to Func would allocate
+	block  StatusBlock
+	blocks []StatusBlock
 }
 
 func (e *Edit) Status() (StatusBlock, error) {
-	sb, err := e.Widget.Status()
+	var err error
+	e.block, err = e.Widget.Status()
 	if err == nil {
-		e.Func(&sb)
+		e.Func(&e.block)
 	}
-	return sb, err
+	return e.block, err
+}
+
+// StatusBlocks edits every block of Widget. It is only called if Widget is a
+// MultiWidget. The returned slice is reused by the next call
+func (e *Edit) StatusBlocks() ([]StatusBlock, error) {
+	var err error
+	e.blocks, err = appendStatusBlocks(e.blocks[:0], e.Widget)
+	if err != nil {
+		return nil, err
+	}
+	for i := range e.blocks {
+		e.Func(&e.blocks[i])
+	}
+	return e.blocks, nil
+}
+
+func (e *Edit) SetUpdater(u Updater) {
+	setUpdater(e.Widget, u)
+}
+
+func (e *Edit) Pause() {
+	pause(e.Widget)
+}
+
+func (e *Edit) Resume() {
+	resume(e.Widget)
+}
+
+func (e *Edit) Close() error {
+	return closeWidget(e.Widget)
+}
+
+func (e *Edit) SaveState() ([]byte, error) {
+	return saveState(e.Widget)
+}
+
+func (e *Edit) RestoreState(state []byte) error {
+	return restoreState(e.Widget, state)
+}
+
+func (e *Edit) WatchedFiles() []string {
+	return appendWatchedFiles(nil, e.Widget)
 }
 
 func (e *Edit) Click(c ClickEvent) bool {
@@ -38,6 +85,20 @@ func (e *Edit) Click(c ClickEvent) bool {
 	return false
 }
 
+// textBuffer is a reused buffer for a Widget's text. Widgets append to buf,
+// then call String, which only allocates if the text changed
+type textBuffer struct {
+	buf  []byte
+	text string
+}
+
+func (t *textBuffer) String() string {
+	if string(t.buf) != t.text {
+		t.text = string(t.buf)
+	}
+	return t.text
+}
+
 // BoolPtr returns &value
 func BoolPtr(value bool) *bool {
 	return &value
@@ -0,0 +1,167 @@
+package my3status
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// A WatchingWidget depends on files, such as a config or data file. The bar is
+// redrawn as soon as any of them change
+type WatchingWidget interface {
+	Widget
+	WatchedFiles() []string
+}
+
+// appendWatchedFiles appends the files watched by w to dst
+func appendWatchedFiles(dst []string, w Widget) []string {
+	if ww, ok := w.(WatchingWidget); ok {
+		return append(dst, ww.WatchedFiles()...)
+	}
+	return dst
+}
+
+// watchDebounce is how long a watched file must be left alone before a change
+// is reported, so a build writing the binary in several steps is only reported
+// once
+const watchDebounce = 250 * time.Millisecond
+
+// watchMask is the set of events which are considered changes. Directories are
+// watched rather than the files, so files which are replaced by renaming
+// another file over them are seen
+const watchMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_ATTRIB | unix.IN_DELETE
+
+// A watcher reports changes to a set of files using inotify
+type watcher struct {
+	file *os.File
+
+	// Changes receives the set of files that changed after every burst of
+	// changes
+	Changes chan map[string]bool
+	done    chan struct{}
+
+	mu    sync.Mutex
+	dirs  map[string]int
+	names map[int]string
+	files map[string]bool
+}
+
+func newWatcher() (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{
+		// a non-blocking file uses the runtime poller, so Close interrupts Read
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		Changes: make(chan map[string]bool, 1),
+		done:    make(chan struct{}),
+		dirs:    map[string]int{},
+		names:   map[int]string{},
+		files:   map[string]bool{},
+	}
+	events := make(chan string)
+	go w.read(events)
+	go w.debounce(events)
+	return w, nil
+}
+
+// Watch adds files to the watched set
+func (w *watcher) Watch(files ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, file := range files {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		if w.files[file] {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if _, ok := w.dirs[dir]; !ok {
+			wd, err := unix.InotifyAddWatch(int(w.file.Fd()), dir, watchMask)
+			if err != nil {
+				return &os.PathError{Op: "watch", Path: dir, Err: err}
+			}
+			w.dirs[dir] = wd
+			w.names[wd] = dir
+		}
+		w.files[file] = true
+	}
+	return nil
+}
+
+// Close stops watching. Pending changes are dropped
+func (w *watcher) Close() error {
+	close(w.done)
+	return w.file.Close()
+}
+
+// read sends the path of every watched file that changes to events
+func (w *watcher) read(events chan<- string) {
+	defer close(events)
+	buf := make([]byte, 16*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(ev.Len)]
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+
+			// the name is padded with NULs
+			name := string(nameBytes)
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+
+			w.mu.Lock()
+			dir, ok := w.names[int(ev.Wd)]
+			path := filepath.Join(dir, name)
+			watched := ok && w.files[path]
+			w.mu.Unlock()
+			if watched {
+				events <- path
+			}
+		}
+	}
+}
+
+// debounce collects events into sets, sending each set to Changes once no
+// events have arrived for watchDebounce
+func (w *watcher) debounce(events <-chan string) {
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	changed := map[string]bool{}
+	for {
+		select {
+		case path, ok := <-events:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			changed[path] = true
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			select {
+			case w.Changes <- changed:
+			case <-w.done:
+				return
+			}
+			changed = map[string]bool{}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package my3status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// Waybar renders a single Widget as a Waybar custom module with
+// "return-type": "json". It is normally used through Config.Waybar
+type Waybar struct {
+	// Widget selects the blocks to render, either by their Name or by the
+	// 1-based position of the Widget in Config.Widgets
+	Widget string
+
+	// the name and instance of the last rendered block, which clicks are sent
+	// to
+	mu       sync.Mutex
+	name     string
+	instance string
+}
+
+type waybarFrame struct {
+	Text       string   `json:"text"`
+	Alt        string   `json:"alt,omitempty"`
+	Tooltip    string   `json:"tooltip,omitempty"`
+	Class      []string `json:"class,omitempty"`
+	Percentage *int     `json:"percentage,omitempty"`
+}
+
+func (w *Waybar) AppendHeader(dst []byte, c Config) []byte {
+	return dst
+}
+
+// AppendFrame appends a line containing the selected blocks. If a MultiWidget
+// is selected it's blocks are joined with spaces
+func (w *Waybar) AppendFrame(dst []byte, blocks []StatusBlock) []byte {
+	frame := waybarFrame{}
+	text := &strings.Builder{}
+	found := false
+	for _, s := range blocks {
+		if !w.matches(s) || s.FullText == "" {
+			continue
+		}
+		if !found {
+			found = true
+			w.mu.Lock()
+			w.name, w.instance = s.Name, s.Instance
+			w.mu.Unlock()
+			frame.Alt = s.Alt
+			frame.Tooltip = s.Tooltip
+			frame.Percentage = s.Percentage
+		} else {
+			text.WriteByte(' ')
+		}
+		// Waybar always parses the text as pango markup
+		if s.Markup == MarkupPango {
+			text.WriteString(s.FullText)
+		} else {
+			text.WriteString(escapePango(s.FullText))
+		}
+		frame.Class = appendWaybarClasses(frame.Class, s)
+	}
+	frame.Text = text.String()
+
+	line, _ := json.Marshal(frame)
+	dst = append(dst, line...)
+	return append(dst, '\n')
+}
+
+func (w *Waybar) matches(s StatusBlock) bool {
+	if s.Name == w.Widget {
+		return true
+	}
+	// the default instance is the widget's position
+	index, _, ok := parseInstance(s.Instance)
+	return ok && strconv.Itoa(index+1) == w.Widget
+}
+
+// appendWaybarClasses appends the CSS classes for s, such as "urgent" or
+// "color-ff0000", which can be used to style the module
+func appendWaybarClasses(classes []string, s StatusBlock) []string {
+	add := func(class string) {
+		for _, c := range classes {
+			if c == class {
+				return
+			}
+		}
+		classes = append(classes, class)
+	}
+	if s.Urgent {
+		add("urgent")
+	}
+	if s.Color != nil {
+		add("color-" + cssColor(s.Color))
+	}
+	if s.Background != nil {
+		add("background-" + cssColor(s.Background))
+	}
+	return classes
+}
+
+// cssColor formats c for use in a class name, as lowercase rrggbb or rrggbbaa
+func cssColor(c color.Color) string {
+	return strings.ToLower(string(appendHexColor(nil, c)[1:]))
+}
+
+func escapePango(text string) string {
+	text = strings.Replace(text, "&", "&amp;", -1)
+	text = strings.Replace(text, "<", "&lt;", -1)
+	return strings.Replace(text, ">", "&gt;", -1)
+}
+
+func (w *Waybar) AppendFooter(dst []byte) []byte {
+	return dst
+}
+
+// ReadClicks reads the button numbers written by WaybarClick, and sends them
+// to the last rendered block
+func (w *Waybar) ReadClicks(in io.Reader, fn func(ClickEvent)) error {
+	return readLines(in, func(line []byte) error {
+		button, err := strconv.Atoi(string(bytes.TrimSpace(line)))
+		if err != nil {
+			return nil
+		}
+		w.mu.Lock()
+		ev := ClickEvent{
+			Name:     w.name,
+			Instance: w.instance,
+			Button:   button,
+		}
+		w.mu.Unlock()
+		if ev.Name != "" {
+			fn(ev)
+		}
+		return nil
+	})
+}
+
+// Waybar runs a single Widget as a Waybar custom module, writing to stdout.
+// widget selects the Widget as described in Waybar.Widget; selecting by
+// position avoids polling the other Widgets. Clicks are read from a FIFO in
+// $XDG_RUNTIME_DIR which is written to by WaybarClick, so the module can be
+// configured with
+//
+//	"custom/cpu": {
+//		"exec": "my3status -waybar cpu",
+//		"return-type": "json",
+//		"on-click": "my3status -waybar-click cpu -button 1"
+//	}
+func (c Config) Waybar(ctx context.Context, widget string) error {
+	if index, err := strconv.Atoi(widget); err == nil {
+		if index < 1 || index > len(c.Widgets) {
+			return fmt.Errorf("waybar: no widget at position %v", index)
+		}
+		// keep the position so the default instance matches
+		widgets := make([]Widget, len(c.Widgets))
+		for i := range widgets {
+			widgets[i] = StatusBlock{}
+		}
+		widgets[index-1] = c.Widgets[index-1]
+		c.Widgets = widgets
+	}
+	c.Renderer = &Waybar{Widget: widget}
+
+	path := waybarFIFO(widget)
+	os.Remove(path)
+	err := unix.Mkfifo(path, 0600)
+	if err != nil {
+		return fmt.Errorf("waybar: unable to create %q: %v", path, err)
+	}
+	defer os.Remove(path)
+
+	// opening the FIFO for writing as well means it never reaches EOF
+	fifo, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("waybar: unable to open %q: %v", path, err)
+	}
+	CloseFileBeforeRestart(fifo)
+	defer fifo.Close()
+
+	return c.Run(ctx, fifo, os.Stdout)
+}
+
+// WaybarClick sends a click with button to a Widget running in Config.Waybar
+func WaybarClick(widget string, button int) error {
+	path := waybarFIFO(widget)
+	fifo, err := os.OpenFile(path, os.O_WRONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("waybar: %q is not running: %v", widget, err)
+	}
+	defer fifo.Close()
+	_, err = fifo.WriteString(strconv.Itoa(button) + "\n")
+	return err
+}
+
+func waybarFIFO(widget string) string {
+	return filepath.Join(runtimeDir(), "my3status-waybar-"+url.PathEscape(widget))
+}
+
+// runtimeDir returns the directory used for sockets and FIFOs
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}